@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readAnswersFile reads a flat KEY: value map from a YAML or JSON answers file.
+// Scalars such as numbers and booleans are read as their text.
+func readAnswersFile(filePath string) (map[string]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading answers file %s: %w", filePath, err)
+	}
+	answers := make(map[string]string)
+	// JSON is valid YAML, so one decoder handles both formats
+	if err := yaml.Unmarshal(data, &answers); err != nil {
+		return nil, fmt.Errorf("error parsing answers file %s: %w", filePath, err)
+	}
+	return answers, nil
+}
+
+// writeAnswersFile records values as an answers file. The format follows the extension:
+// .json writes JSON, anything else YAML. Omitted secret keys are listed in a YAML comment.
+func writeAnswersFile(filePath string, values map[string]string, omitted []string) error {
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		data, err = json.MarshalIndent(values, "", "  ")
+		data = append(data, '\n')
+	} else {
+		var buf bytes.Buffer
+		buf.WriteString("# Recorded by setup-env. Replay with: setup-env --answers " + filepath.Base(filePath) + "\n")
+		if len(omitted) > 0 {
+			buf.WriteString("# Secret values are not recorded: " + strings.Join(omitted, ", ") + "\n")
+		}
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		err = encoder.Encode(values)
+		data = buf.Bytes()
+	}
+	if err != nil {
+		return fmt.Errorf("error encoding answers file %s: %w", filePath, err)
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// recordIfRequested records the answers of a finished run when --record was given. It runs
+// once the values are saved, so a cancelled review or a failed write records nothing.
+func (m *model) recordIfRequested(values map[string]string) {
+	if m.opts.recordFile == "" || m.opts.dryRun {
+		return
+	}
+	if err := m.recordAnswers(values); err != nil {
+		fmt.Printf("Warning: could not record answers: %v\n", err)
+	}
+}
+
+// recordAnswers writes the collected form values, minus secrets, to the --record file
+func (m *model) recordAnswers(collected map[string]string) error {
+	values := make(map[string]string, len(collected))
+	var omitted []string
+	for _, envVar := range m.envVars {
+		value, ok := collected[envVar.Key]
+		if !ok {
+			continue
+		}
+		if isSecret(envVar) {
+			omitted = append(omitted, envVar.Key)
+			continue
+		}
+		values[envVar.Key] = value
+	}
+	return writeAnswersFile(m.opts.recordFile, values, omitted)
+}
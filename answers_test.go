@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAnswersFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("yaml with scalars", func(t *testing.T) {
+		path := createTempFileForModel(t, tmpDir, "answers.yaml", "DB_HOST: db.local\nDB_PORT: 5432\nDEBUG: true\nEMPTY: \"\"\n")
+		answers, err := readAnswersFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "db.local", "DB_PORT": "5432", "DEBUG": "true", "EMPTY": ""}, answers)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		path := createTempFileForModel(t, tmpDir, "answers.json", `{"DB_HOST": "db.local", "DB_PORT": 5432}`)
+		answers, err := readAnswersFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "db.local", "DB_PORT": "5432"}, answers)
+	})
+
+	t.Run("nested values are rejected", func(t *testing.T) {
+		path := createTempFileForModel(t, tmpDir, "nested.yaml", "DB:\n  HOST: x\n")
+		_, err := readAnswersFile(path)
+		assert.ErrorContains(t, err, "error parsing answers file")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readAnswersFile(filepath.Join(tmpDir, "missing.yaml"))
+		assert.ErrorContains(t, err, "error reading answers file")
+	})
+}
+
+func TestWriteAnswersFileRoundTrip(t *testing.T) {
+	values := map[string]string{"DB_HOST": "db.local", "DB_PORT": "5432", "QUOTED": "a: b # c"}
+	for _, name := range []string{"answers.yaml", "answers.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, writeAnswersFile(path, values, []string{"DB_PASSWORD"}))
+			answers, err := readAnswersFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, values, answers)
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "answers.yml")
+	require.NoError(t, writeAnswersFile(path, values, []string{"DB_PASSWORD"}))
+	content, _ := os.ReadFile(path)
+	assert.Contains(t, string(content), "# Secret values are not recorded: DB_PASSWORD")
+}
+
+func TestIsSecret(t *testing.T) {
+	assert.True(t, isSecret(EnvVar{Key: "DB_PASSWORD"}))
+	assert.True(t, isSecret(EnvVar{Key: "github_token"}))
+	assert.True(t, isSecret(EnvVar{Key: "STRIPE_KEY", Secret: true}))
+	assert.False(t, isSecret(EnvVar{Key: "DB_HOST"}))
+}
+
+func TestModelAnswers(t *testing.T) {
+	setupDir := func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "DB_HOST=localhost\nDB_PASSWORD=\nSTRIPE_KEY= # @secret\nAPP_NAME=app"})
+	}
+
+	t.Run("answered fields are prefilled and not prompted", func(t *testing.T) {
+		setupDir(t)
+
+		m := newModel(options{answers: map[string]string{"DB_HOST": "db.ci", "UNKNOWN": "x"}})
+		_ = m.Init()
+		require.Nil(t, m.err)
+		assert.Equal(t, "db.ci", m.fieldValue(0))
+		assert.Equal(t, "DB_HOST  [from answers]", m.fieldTitle(0))
+		assert.Equal(t, []int{1, 2, 3}, m.visibleFieldIndices())
+		assert.NotContains(t, m.View(), "DB_HOST")
+	})
+
+	t.Run("fully answered goes straight to the review", func(t *testing.T) {
+		setupDir(t)
+
+		answers := map[string]string{"DB_HOST": "db.ci", "DB_PASSWORD": "pw", "STRIPE_KEY": "sk", "APP_NAME": "ci"}
+		m := newModel(options{answers: answers})
+		cmd := m.Init()
+		require.Nil(t, m.err)
+		require.NotNil(t, cmd)
+		assert.True(t, m.confirming)
+		assert.Nil(t, m.form)
+		assert.Equal(t, answers, m.envValuesToSave)
+	})
+
+	t.Run("invalid answers are refused", func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "API_TOKEN= # @required\nAPI_URL=https://api.example.com # @type=url\nAPP_NAME=app"})
+
+		m := newModel(options{answers: map[string]string{"API_TOKEN": "", "API_URL": "https://[::1", "APP_NAME": "ci"}})
+		m.Init()
+		require.ErrorIs(t, m.err, errValidation)
+		assert.ErrorContains(t, m.err, "API_TOKEN is required")
+		assert.ErrorContains(t, m.err, "API_URL: ")
+		assert.False(t, m.confirming)
+		assert.NoFileExists(t, ".env")
+	})
+
+	t.Run("record leaves secrets out", func(t *testing.T) {
+		setupDir(t)
+
+		m := newModel(options{recordFile: "recorded.yaml"})
+		_ = m.Init()
+		require.Nil(t, m.err)
+		password, stripeKey := "hunter2", "sk_live"
+		m.fields[1].(*huh.Input).Value(&password)
+		m.fields[2].(*huh.Input).Value(&stripeKey)
+		require.NoError(t, m.prepareForConfirmation())
+		assert.NoFileExists(t, "recorded.yaml", "nothing is recorded before the changes are confirmed")
+		require.NoError(t, m.save())
+
+		recorded, err := readAnswersFile("recorded.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "localhost", "APP_NAME": "app"}, recorded)
+
+		// Replaying the recording only prompts for the secrets
+		replay := newModel(options{answers: recorded})
+		_ = replay.Init()
+		assert.Equal(t, []int{1, 2}, replay.visibleFieldIndices())
+	})
+}
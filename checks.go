@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+// Reachability checks selected with @check=<kind>
+const (
+	checkTCP  = "tcp"  // Connect to host:port
+	checkHTTP = "http" // Request the URL, any response below 500 passes
+	checkDNS  = "dns"  // Resolve the hostname
+)
+
+// checkTimeout bounds every check. Tests lower it.
+var checkTimeout = 3 * time.Second
+
+// defaultPorts are used for tcp checks of URLs without an explicit port
+var defaultPorts = map[string]string{
+	"http": "80", "https": "443", "postgres": "5432", "postgresql": "5432", "mysql": "3306",
+	"redis": "6379", "rediss": "6379", "amqp": "5672", "amqps": "5671", "mongodb": "27017",
+}
+
+// checkResult is the latest outcome of the check of one variable
+type checkResult struct {
+	Kind    string
+	Target  string // What was checked, such as db.local:5432
+	Pending bool
+	Err     error
+}
+
+func (r checkResult) String() string {
+	switch {
+	case r.Pending:
+		return fmt.Sprintf("… %s %s checking", r.Kind, r.Target)
+	case r.Err != nil && r.Target == "":
+		return fmt.Sprintf("✗ %s check: %v", r.Kind, r.Err)
+	case r.Err != nil:
+		return fmt.Sprintf("✗ %s %s unreachable: %v", r.Kind, r.Target, r.Err)
+	default:
+		return fmt.Sprintf("✓ %s %s reachable", r.Kind, r.Target)
+	}
+}
+
+// failed reports whether the check finished without reaching its target
+func (r checkResult) failed() bool {
+	return !r.Pending && r.Err != nil
+}
+
+// checkResultMsg delivers the outcome of a check started by checkCmds
+type checkResultMsg struct {
+	Key    string
+	Result checkResult
+}
+
+// checkTarget works out what to check for a value. A host without a port takes the port from
+// the matching _PORT variable, so DB_HOST is checked with DB_PORT.
+func checkTarget(envVar EnvVar, values map[string]string) (string, error) {
+	value := strings.TrimSpace(values[envVar.Key])
+	var host, port string
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && u.Host != "" {
+		host, port = u.Hostname(), u.Port()
+		if port == "" {
+			port = defaultPorts[strings.ToLower(u.Scheme)]
+		}
+	} else if h, p, err := net.SplitHostPort(value); err == nil {
+		host, port = h, p
+	} else {
+		host = value
+	}
+	if port == "" {
+		if base, ok := strings.CutSuffix(envVar.Key, "_HOST"); ok {
+			port = strings.TrimSpace(values[base+"_PORT"])
+		}
+	}
+
+	switch envVar.Check {
+	case checkTCP:
+		if port == "" {
+			return "", fmt.Errorf("no port in %q", value)
+		}
+		return net.JoinHostPort(host, port), nil
+	case checkHTTP:
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%q is not an http(s) URL", value)
+		}
+		return value, nil
+	case checkDNS:
+		if host == "" {
+			return "", fmt.Errorf("no hostname in %q", value)
+		}
+		return host, nil
+	default:
+		return "", fmt.Errorf("unknown check %q, expected tcp, http or dns", envVar.Check)
+	}
+}
+
+// checkReads reports whether the check target of envVar is worked out from the value of key:
+// its own value, or the _PORT variable that goes with a _HOST one
+func checkReads(envVar EnvVar, key string) bool {
+	if envVar.Key == key {
+		return true
+	}
+	base, ok := strings.CutSuffix(envVar.Key, "_HOST")
+	return ok && key == base+"_PORT"
+}
+
+// runCheck tries to reach the target within checkTimeout
+func runCheck(kind, target string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	switch kind {
+	case checkTCP:
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case checkHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("responded %s", resp.Status)
+		}
+		return nil
+	case checkDNS:
+		_, err := net.DefaultResolver.LookupHost(ctx, target)
+		return err
+	}
+	return fmt.Errorf("unknown check %q", kind)
+}
+
+// checkCmds starts a check for every @check variable whose target changed since its last check.
+// Checks that read the focused field wait until the user leaves it or submits the form, so
+// typing does not start one per keystroke. Nothing runs with --offline.
+func (m *model) checkCmds() []tea.Cmd {
+	if m.opts.offline {
+		return nil
+	}
+	values, err := m.collectValues()
+	if err != nil {
+		return nil
+	}
+	if m.checks == nil {
+		m.checks = make(map[string]checkResult)
+	}
+	focusedKey := ""
+	if i := m.focusedFieldIndex(); i != -1 && m.form.State == huh.StateNormal {
+		focusedKey = m.envVars[i].Key
+	}
+	var cmds []tea.Cmd
+	for _, envVar := range m.envVars {
+		if envVar.Check == "" || (focusedKey != "" && checkReads(envVar, focusedKey)) {
+			continue
+		}
+		if values[envVar.Key] == "" {
+			delete(m.checks, envVar.Key)
+			continue
+		}
+		target, err := checkTarget(envVar, values)
+		if err != nil {
+			m.checks[envVar.Key] = checkResult{Kind: envVar.Check, Err: err}
+			continue
+		}
+		if previous, ok := m.checks[envVar.Key]; ok && previous.Target == target {
+			continue
+		}
+		result := checkResult{Kind: envVar.Check, Target: target, Pending: true}
+		m.checks[envVar.Key] = result
+		key := envVar.Key
+		cmds = append(cmds, func() tea.Msg {
+			result.Pending = false
+			result.Err = runCheck(result.Kind, result.Target)
+			return checkResultMsg{Key: key, Result: result}
+		})
+	}
+	return cmds
+}
+
+// applyCheckResult stores a finished check unless the value changed while it ran
+func (m *model) applyCheckResult(msg checkResultMsg) {
+	if current, ok := m.checks[msg.Key]; !ok || current.Target != msg.Result.Target {
+		return
+	}
+	m.checks[msg.Key] = msg.Result
+	m.refreshBadges()
+}
+
+// runChecks runs the checks one after another, for modes without an event loop
+func (m *model) runChecks() {
+	for key, result := range m.checks {
+		if result.Pending {
+			delete(m.checks, key) // Started by Init without an event loop to finish it
+		}
+	}
+	for _, cmd := range m.checkCmds() {
+		if msg, ok := cmd().(checkResultMsg); ok {
+			m.applyCheckResult(msg)
+		}
+	}
+}
+
+// badge is the short form of the result shown in field titles
+func (r checkResult) badge() string {
+	switch {
+	case r.Pending:
+		return "… checking"
+	case r.Err != nil:
+		return "✗ unreachable"
+	default:
+		return "✓ reachable"
+	}
+}
+
+// checkLines returns one line per check result, in template order
+func (m *model) checkLines() []string {
+	var lines []string
+	for _, envVar := range m.envVars {
+		if result, ok := m.checks[envVar.Key]; ok {
+			lines = append(lines, envVar.Key+": "+result.String())
+		}
+	}
+	return lines
+}
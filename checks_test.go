@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		envVar  EnvVar
+		values  map[string]string
+		want    string
+		wantErr string
+	}{
+		{"tcp host and port", EnvVar{Key: "REDIS_ADDR", Check: checkTCP}, map[string]string{"REDIS_ADDR": "cache:6380"}, "cache:6380", ""},
+		{"tcp url with default port", EnvVar{Key: "DATABASE_URL", Check: checkTCP}, map[string]string{"DATABASE_URL": "postgres://app:pw@db.local/app"}, "db.local:5432", ""},
+		{"tcp port from sibling", EnvVar{Key: "DB_HOST", Check: checkTCP}, map[string]string{"DB_HOST": "db.local", "DB_PORT": "5433"}, "db.local:5433", ""},
+		{"tcp without port", EnvVar{Key: "SMTP_SERVER", Check: checkTCP}, map[string]string{"SMTP_SERVER": "mail.local"}, "", `no port in "mail.local"`},
+		{"http url", EnvVar{Key: "API_URL", Check: checkHTTP}, map[string]string{"API_URL": "https://api.local/health"}, "https://api.local/health", ""},
+		{"http without url", EnvVar{Key: "API_URL", Check: checkHTTP}, map[string]string{"API_URL": "api.local"}, "", "not an http(s) URL"},
+		{"dns from url", EnvVar{Key: "API_URL", Check: checkDNS}, map[string]string{"API_URL": "https://api.local:8443/v1"}, "api.local", ""},
+		{"dns bare host", EnvVar{Key: "DB_HOST", Check: checkDNS}, map[string]string{"DB_HOST": "db.local"}, "db.local", ""},
+		{"unknown kind", EnvVar{Key: "DB_HOST", Check: "ping"}, map[string]string{"DB_HOST": "db.local"}, "", `unknown check "ping"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkTarget(tt.envVar, tt.values)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// closedPort returns a local address nothing listens on
+func closedPort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestRunCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	assert.NoError(t, runCheck(checkTCP, listener.Addr().String()))
+	assert.Error(t, runCheck(checkTCP, closedPort(t)))
+	assert.NoError(t, runCheck(checkHTTP, healthy.URL))
+	assert.ErrorContains(t, runCheck(checkHTTP, failing.URL), "responded 503 Service Unavailable")
+	assert.Error(t, runCheck(checkHTTP, "http://"+closedPort(t)))
+	assert.NoError(t, runCheck(checkDNS, "localhost"))
+}
+
+func TestModelChecks(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	_, openPort, _ := net.SplitHostPort(listener.Addr().String())
+	_, deadPort, _ := net.SplitHostPort(closedPort(t))
+
+	template := "APP_NAME=app\nDB_HOST=127.0.0.1 # Database host @check=tcp\nDB_PORT=" + openPort + "\nCACHE_ADDR=127.0.0.1:" + deadPort + " # @check=tcp\n"
+	setupDir := func(t *testing.T, existing string) {
+		files := map[string]string{".env.example": template}
+		if existing != "" {
+			files[".env"] = existing
+		}
+		chdirTemp(t, files)
+	}
+
+	t.Run("results in titles and the review", func(t *testing.T) {
+		setupDir(t, "")
+		m := initialModel()
+		m.Init()
+		assert.Contains(t, m.titles[1], "… checking", "checks start with the form")
+
+		m.runChecks()
+		assert.Contains(t, m.titles[1], "✓ reachable")
+		assert.Contains(t, m.titles[3], "✗ unreachable")
+
+		require.NoError(t, m.prepareForConfirmation())
+		view := m.reviewView()
+		assert.Contains(t, view, "DB_HOST: ✓ tcp 127.0.0.1:"+openPort+" reachable")
+		assert.Contains(t, view, "CACHE_ADDR: ✗ tcp 127.0.0.1:"+deadPort+" unreachable")
+		assert.Contains(t, view, "[x] ✗ ", "the change with a failed check is marked")
+	})
+
+	t.Run("stale results are dropped", func(t *testing.T) {
+		setupDir(t, "")
+		m := initialModel()
+		m.Init()
+		cmds := m.checkCmds()
+		assert.Empty(t, cmds, "checks already started are not repeated")
+
+		m.setFieldValue(2, deadPort) // DB_PORT changes the target of DB_HOST
+		cmds = m.checkCmds()
+		require.Len(t, cmds, 1)
+		m.applyCheckResult(checkResultMsg{Key: "DB_HOST", Result: checkResult{Kind: checkTCP, Target: "127.0.0.1:" + openPort}})
+		assert.True(t, m.checks["DB_HOST"].Pending, "a result for the old target is ignored")
+		m.applyCheckResult(cmds[0]().(checkResultMsg))
+		assert.True(t, m.checks["DB_HOST"].failed())
+	})
+
+	t.Run("checks reading the focused field wait until it loses focus", func(t *testing.T) {
+		setupDir(t, "")
+		m := initialModel()
+		m.Init()
+		m.runChecks()
+		m.form.NextField()
+		m.form.NextField()
+		require.Equal(t, 2, m.focusedFieldIndex())
+
+		m.Update(tea.KeyMsg{Type: tea.KeyBackspace}) // DB_PORT is being typed, DB_HOST waits
+		assert.False(t, m.checks["DB_HOST"].Pending)
+		assert.Equal(t, "127.0.0.1:"+openPort, m.checks["DB_HOST"].Target)
+
+		m.form.NextField()
+		cmds := m.checkCmds()
+		require.Len(t, cmds, 1, "leaving DB_PORT checks DB_HOST once")
+		assert.Equal(t, "DB_HOST", cmds[0]().(checkResultMsg).Key)
+	})
+
+	t.Run("submitting checks the last field", func(t *testing.T) {
+		setupDir(t, "")
+		m := initialModel()
+		m.Init()
+		m.runChecks()
+		for m.focusedFieldIndex() != 3 {
+			m.form.NextField()
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+		assert.Equal(t, "127.0.0.1:"+deadPort, m.checks["CACHE_ADDR"].Target, "not checked while typing")
+
+		m.form.State = huh.StateCompleted
+		cmds := m.checkCmds()
+		require.Len(t, cmds, 1)
+		assert.Equal(t, "CACHE_ADDR", cmds[0]().(checkResultMsg).Key)
+	})
+
+	t.Run("plain mode and offline", func(t *testing.T) {
+		setupDir(t, "APP_NAME=app\nDB_HOST=127.0.0.1\nDB_PORT="+openPort+"\nCACHE_ADDR=127.0.0.1:"+deadPort+"\n")
+		var out bytes.Buffer
+		require.NoError(t, runPlain(initialModel(), strings.NewReader(""), &out, false))
+		assert.Contains(t, out.String(), "Checks:\n  DB_HOST: ✓ tcp")
+
+		out.Reset()
+		m := newModel(options{offline: true})
+		require.NoError(t, runPlain(m, strings.NewReader(""), &out, false))
+		assert.NotContains(t, out.String(), "Checks:")
+		assert.Empty(t, m.checks)
+	})
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configFileName = ".setup-env.yaml"
+
+// Backup policies for the existing .env before it is overwritten
+const (
+	backupSingle      = "single"      // Copy to .env.old, replacing the previous backup
+	backupTimestamped = "timestamped" // Copy to .env.old.<date>-<time>, keeping every backup
+	backupNone        = "none"
+)
+
+// settings is the tool behavior a project can set in .setup-env.yaml.
+// Empty fields are left to the defaults.
+type settings struct {
+	Template         string              `yaml:"template,omitempty"`
+	Extends          []string            `yaml:"extends,omitempty"` // Base templates included before the template
+	EnvFile          string              `yaml:"env_file,omitempty"`
+	Backup           string              `yaml:"backup,omitempty"`
+	Theme            string              `yaml:"theme,omitempty"`
+	SecretPatterns   []string            `yaml:"secret_patterns,omitempty"`
+	ProductionHosts  []string            `yaml:"production_hosts,omitempty"`
+	TokenPatterns    map[string]string   `yaml:"token_patterns,omitempty"`        // Credential formats by the name shown in warnings
+	SecretTokens     []string            `yaml:"secret_tokens_allowed,omitempty"` // Token patterns not flagged in secret variables
+	EntropyThreshold *float64            `yaml:"entropy_threshold,omitempty"`     // Bits per character that look random, 0 turns the check off
+	KeyPreset        string              `yaml:"key_preset,omitempty"`            // Bindings the keys setting starts from
+	Keys             map[string][]string `yaml:"keys,omitempty"`
+	ConfirmQuit      *bool               `yaml:"confirm_quit,omitempty"` // Ask before quitting with unsaved edits
+}
+
+func defaultSettings() settings {
+	confirmQuit := true
+	entropyThreshold := defaultEntropyThreshold
+	return settings{
+		Template:         ".env.example",
+		EnvFile:          ".env",
+		Backup:           backupSingle,
+		Theme:            defaultThemeName,
+		SecretPatterns:   defaultSecretKeyPatterns,
+		ProductionHosts:  defaultProductionHosts,
+		TokenPatterns:    defaultTokenPatterns,
+		EntropyThreshold: &entropyThreshold,
+		KeyPreset:        defaultKeyPreset,
+		ConfirmQuit:      &confirmQuit,
+	}
+}
+
+// findConfigFile walks up from dir to the first directory holding .setup-env.yaml.
+// It returns "" when there is none.
+func findConfigFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readConfigFile parses a .setup-env.yaml file. Unknown settings are an error.
+func readConfigFile(path string) (settings, error) {
+	var s settings
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("error reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return s, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// merge overrides s with every field set in other. Key bindings are merged per action.
+func (s *settings) merge(other settings) {
+	if other.Template != "" {
+		s.Template = other.Template
+	}
+	if other.Extends != nil {
+		s.Extends = other.Extends
+	}
+	if other.EnvFile != "" {
+		s.EnvFile = other.EnvFile
+	}
+	if other.Backup != "" {
+		s.Backup = other.Backup
+	}
+	if other.Theme != "" {
+		s.Theme = other.Theme
+	}
+	if other.SecretPatterns != nil {
+		s.SecretPatterns = other.SecretPatterns
+	}
+	if other.ProductionHosts != nil {
+		s.ProductionHosts = other.ProductionHosts
+	}
+	if other.TokenPatterns != nil {
+		s.TokenPatterns = other.TokenPatterns
+	}
+	if other.SecretTokens != nil {
+		s.SecretTokens = other.SecretTokens
+	}
+	if other.EntropyThreshold != nil {
+		s.EntropyThreshold = other.EntropyThreshold
+	}
+	if other.KeyPreset != "" {
+		s.KeyPreset = other.KeyPreset
+	}
+	if other.ConfirmQuit != nil {
+		s.ConfirmQuit = other.ConfirmQuit
+	}
+	for action, keys := range other.Keys {
+		if s.Keys == nil {
+			s.Keys = make(map[string][]string)
+		}
+		s.Keys[action] = keys
+	}
+}
+
+// validate checks the values that are not checked where they are used
+func (s settings) validate() error {
+	switch s.Backup {
+	case backupSingle, backupTimestamped, backupNone:
+	default:
+		return fmt.Errorf("unknown backup policy %q, expected %s, %s or %s", s.Backup, backupSingle, backupTimestamped, backupNone)
+	}
+	for _, template := range append([]string{s.Template}, s.Extends...) {
+		if isTemplateURL(template) {
+			if _, _, err := splitPin(template); err != nil {
+				return err
+			}
+		}
+	}
+	if _, ok := keyPresets[s.KeyPreset]; !ok && s.KeyPreset != "" {
+		return fmt.Errorf("unknown key preset %q, expected one of %s", s.KeyPreset, strings.Join(keyPresetNames(), ", "))
+	}
+	if _, ok := themes[s.Theme]; !ok {
+		return fmt.Errorf("unknown theme %q, expected one of %s", s.Theme, strings.Join(themeNames(), ", "))
+	}
+	if s.EntropyThreshold != nil && *s.EntropyThreshold < 0 {
+		return fmt.Errorf("entropy_threshold must not be negative, got %g", *s.EntropyThreshold)
+	}
+	_, err := s.leakRules()
+	return err
+}
+
+// loadSettings merges the defaults, the nearest .setup-env.yaml and the flag overrides.
+// It also returns the path of the config file, "" when none was found.
+func loadSettings(dir string, overrides settings) (settings, string, error) {
+	s := defaultSettings()
+	path, err := findConfigFile(dir)
+	if err != nil {
+		return s, "", err
+	}
+	if path != "" {
+		fileSettings, err := readConfigFile(path)
+		if err != nil {
+			return s, path, err
+		}
+		if err := fileSettings.resolvePaths(filepath.Dir(path)); err != nil {
+			return s, path, err
+		}
+		s.merge(fileSettings)
+	}
+	s.merge(overrides)
+	return s, path, s.validate()
+}
+
+// resolvePaths rewrites the template, extends and env_file paths of a config file, which are
+// relative to the directory of the file, to paths relative to the working directory
+func (s *settings) resolvePaths(configDir string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	resolve := func(path string) string {
+		if path == "" || isTemplateURL(path) || filepath.IsAbs(path) {
+			return path
+		}
+		resolved := filepath.Join(configDir, path)
+		if rel, err := filepath.Rel(wd, resolved); err == nil {
+			return rel
+		}
+		return resolved
+	}
+	s.Template = resolve(s.Template)
+	for i, extends := range s.Extends {
+		s.Extends[i] = resolve(extends)
+	}
+	s.EnvFile = resolve(s.EnvFile)
+	return nil
+}
+
+// leakRules returns the leaked credential detectors configured by the settings
+func (s settings) leakRules() (*leakRules, error) {
+	threshold := defaultEntropyThreshold
+	if s.EntropyThreshold != nil {
+		threshold = *s.EntropyThreshold
+	}
+	return newLeakRules(s.TokenPatterns, s.SecretTokens, threshold, s.ProductionHosts)
+}
+
+// keyMap returns the default bindings with the key preset and then the configured keys applied
+func (s settings) keyMap() (keyMap, error) {
+	keys := defaultKeyMap()
+	if err := keys.rebind(keyPresets[s.KeyPreset]); err != nil {
+		return keys, err
+	}
+	return keys, keys.rebind(s.Keys)
+}
+
+// printSettings writes the effective settings as YAML, including every key binding
+func printSettings(w io.Writer, s settings, path string) error {
+	keys, err := s.keyMap()
+	if err != nil {
+		return err
+	}
+	s.Keys = keys.keysByAction()
+	if path == "" {
+		fmt.Fprintf(w, "# No %s found, showing defaults and flags\n", configFileName)
+	} else {
+		fmt.Fprintf(w, "# Settings from %s, defaults and flags\n", path)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(s); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSettings(t *testing.T) {
+	t.Run("defaults without a config file", func(t *testing.T) {
+		dir := t.TempDir()
+		s, path, err := loadSettings(dir, settings{})
+		require.NoError(t, err)
+		assert.Equal(t, "", path)
+		assert.Equal(t, defaultSettings(), s)
+	})
+
+	t.Run("found by walking up, flags override", func(t *testing.T) {
+		root := chdirTemp(t, map[string]string{configFileName: `template: config/env.template
+env_file: .env.local
+theme: dracula
+secret_patterns: [PASS, PIN]
+entropy_threshold: 4.5
+confirm_quit: false
+keys:
+  quit: [ctrl+q]
+`})
+		require.NoError(t, os.MkdirAll("services/api", 0755))
+		require.NoError(t, os.Chdir("services/api"))
+
+		s, path, err := loadSettings(".", settings{Theme: "base", Backup: backupNone})
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, configFileName), path)
+		assert.Equal(t, filepath.Join("..", "..", "config", "env.template"), s.Template, "paths are relative to the config file")
+		assert.Equal(t, filepath.Join("..", "..", ".env.local"), s.EnvFile)
+		assert.Equal(t, "base", s.Theme, "flags override the file")
+		assert.Equal(t, backupNone, s.Backup)
+		assert.Equal(t, []string{"PASS", "PIN"}, s.SecretPatterns)
+		assert.Equal(t, defaultProductionHosts, s.ProductionHosts)
+		assert.Equal(t, defaultTokenPatterns, s.TokenPatterns)
+		require.NotNil(t, s.EntropyThreshold)
+		assert.Equal(t, 4.5, *s.EntropyThreshold)
+		assert.Equal(t, map[string][]string{"quit": {"ctrl+q"}}, s.Keys)
+		require.NotNil(t, s.ConfirmQuit)
+		assert.False(t, *s.ConfirmQuit)
+		assert.Equal(t, defaultKeyPreset, s.KeyPreset)
+	})
+
+	t.Run("paths from flags stay relative to the working directory", func(t *testing.T) {
+		chdirTemp(t, map[string]string{configFileName: "template: env.template\nextends: [https://example.com/base.env, /etc/base.env, base.env]\n"})
+		require.NoError(t, os.Mkdir("api", 0755))
+		require.NoError(t, os.Chdir("api"))
+
+		s, _, err := loadSettings(".", settings{EnvFile: ".env.local"})
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join("..", "env.template"), s.Template)
+		assert.Equal(t, []string{"https://example.com/base.env", "/etc/base.env", filepath.Join("..", "base.env")}, s.Extends, "URLs and absolute paths are kept")
+		assert.Equal(t, ".env.local", s.EnvFile)
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown setting", "colour: red\n", "field colour not found"},
+		{"unknown theme", "theme: neon\n", `unknown theme "neon"`},
+		{"unknown backup policy", "backup: always\n", `unknown backup policy "always"`},
+		{"unknown key preset", "key_preset: emacs\n", `unknown key preset "emacs"`},
+		{"invalid token pattern", "token_patterns: {a broken key: \"(\"}\n", `invalid token pattern "a broken key"`},
+		{"unknown token pattern allowed in secrets", "secret_tokens_allowed: [a Datadog key]\n", `unknown token pattern "a Datadog key"`},
+		{"negative entropy threshold", "entropy_threshold: -1\n", "entropy_threshold must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createTempFileForModel(t, dir, configFileName, tt.content)
+			_, _, err := loadSettings(dir, settings{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestSettingsKeyMap(t *testing.T) {
+	keys, err := settings{Keys: map[string][]string{"review_toggle": {"enter"}}}.keyMap()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"enter"}, keys.ReviewToggle.Keys())
+	assert.Equal(t, "accept/reject", keys.ReviewToggle.Help().Desc)
+	assert.Equal(t, defaultKeyMap().Quit.Keys(), keys.Quit.Keys())
+
+	keys, err = settings{KeyPreset: "vim", Keys: map[string][]string{"prev": {"shift+tab"}}}.keyMap()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ctrl+q", "ctrl+c"}, keys.Quit.Keys(), "the preset keeps esc from quitting")
+	assert.Equal(t, []string{"esc"}, keys.Close.Keys())
+	assert.Contains(t, keys.Next.Keys(), "alt+j")
+	assert.Equal(t, []string{"shift+tab"}, keys.Prev.Keys(), "the keys setting applies on top of the preset")
+
+	_, err = settings{Keys: map[string][]string{"jump": {"g"}}}.keyMap()
+	assert.ErrorContains(t, err, `unknown key action "jump"`)
+	_, err = settings{Keys: map[string][]string{"quit": {}}}.keyMap()
+	assert.ErrorContains(t, err, `no keys given for action "quit"`)
+}
+
+func TestPrintSettings(t *testing.T) {
+	s := defaultSettings()
+	s.Keys = map[string][]string{"quit": {"ctrl+q"}}
+	var out bytes.Buffer
+	require.NoError(t, printSettings(&out, s, "/project/.setup-env.yaml"))
+
+	printed := out.String()
+	assert.Contains(t, printed, "# Settings from /project/.setup-env.yaml")
+	assert.Contains(t, printed, "template: .env.example\n")
+	assert.Contains(t, printed, "backup: single\n")
+	assert.Contains(t, printed, "  quit:\n    - ctrl+q\n")
+	assert.Contains(t, printed, "  review_edit:\n    - e\n", "every binding is printed")
+
+	out.Reset()
+	require.NoError(t, printSettings(&out, defaultSettings(), ""))
+	assert.Contains(t, out.String(), "# No .setup-env.yaml found")
+}
+
+func TestBackupPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		backups string // Glob of the backups expected after saving
+		count   int
+	}{
+		{backupSingle, ".env.old", 1},
+		{backupTimestamped, ".env.old.*", 1},
+		{backupNone, ".env.old*", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			chdirTemp(t, map[string]string{".env.example": "KEY=value", ".env": "KEY=old"})
+
+			m := newModel(options{backup: tt.policy})
+			m.Init()
+			require.NoError(t, m.actuallyWriteEnvFile(map[string]string{"KEY": "new"}))
+
+			backups, err := filepath.Glob(tt.backups)
+			require.NoError(t, err)
+			assert.Len(t, backups, tt.count)
+			for _, backup := range backups {
+				content, err := os.ReadFile(backup)
+				require.NoError(t, err)
+				assert.Equal(t, "KEY=old", string(content))
+			}
+		})
+	}
+}
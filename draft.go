@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+// draft holds the answers of a run that was cancelled before saving, so the next run can
+// resume them. It is stored in a sidecar file next to .env.
+type draft struct {
+	Template string            `json:"template"`
+	Saved    time.Time         `json:"saved"`
+	Field    string            `json:"field,omitempty"`   // Key of the field that had focus
+	Values   map[string]string `json:"values"`            // Values edited in the cancelled run, secrets excluded
+	Secrets  *encryptedFile    `json:"secrets,omitempty"` // Edited secrets, sealed like .env.enc
+	Skipped  []string          `json:"skipped,omitempty"` // Edited secrets left out for lack of a passphrase
+}
+
+// draftFilePath returns the sidecar path holding the unfinished answers for an .env file
+func draftFilePath(envPath string) string {
+	return envPath + ".draft"
+}
+
+// readDraft reads a draft file. A missing file is not an error and yields a nil draft.
+func readDraft(filePath string) (*draft, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading %s: %w", filePath, err)
+	}
+	var d draft
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", filePath, err)
+	}
+	return &d, nil
+}
+
+// writeDraft writes the draft file, readable by the user only since it holds plain values
+func writeDraft(filePath string, d draft) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(data, '\n'), 0600)
+}
+
+// saveDraft keeps the values edited in this session when the run is cancelled. Secrets are
+// sealed with the passphrase of .env.enc when --key-file or $SETUP_ENV_PASSPHRASE gives one,
+// and left out otherwise. Without edits an older draft is removed.
+func (m *model) saveDraft() {
+	if m.opts.dryRun || len(m.fields) == 0 || m.draftForm != nil {
+		return // Nothing loaded yet, or the draft found at startup is still being offered
+	}
+	d := draft{Template: templateFilePath, Saved: time.Now(), Values: make(map[string]string)}
+	if i := m.focusedFieldIndex(); i != -1 {
+		d.Field = m.envVars[i].Key
+	}
+	secrets := make(map[string]string)
+	var secretKeys []string
+	for i, envVar := range m.envVars {
+		value := m.fieldValue(i)
+		if value == m.initialValues[i] {
+			continue
+		}
+		if isSecret(envVar) {
+			secrets[envVar.Key] = value
+			secretKeys = append(secretKeys, envVar.Key)
+		} else {
+			d.Values[envVar.Key] = value
+		}
+	}
+	if len(d.Values) == 0 && len(secrets) == 0 {
+		m.removeDraft()
+		return
+	}
+	if len(secrets) > 0 {
+		passphrase, err := readPassphrase(m.opts.keyFile, false, false)
+		if err == nil {
+			d.Secrets, err = sealValues(secrets, passphrase, nil)
+		}
+		if err != nil {
+			d.Secrets, d.Skipped = nil, secretKeys
+		}
+	}
+
+	path := draftFilePath(envOutputFilePath)
+	if err := writeDraft(path, d); err != nil {
+		fmt.Printf("Warning: could not save your answers to %s: %v\n", path, err)
+		return
+	}
+	fmt.Printf("Saved your unfinished answers to %s, the next run offers to resume them.\n", path)
+	if len(d.Skipped) > 0 {
+		fmt.Printf("Left out the secrets %s, set %s or pass --key-file to keep them encrypted.\n", strings.Join(d.Skipped, ", "), passphraseEnvVar)
+	}
+}
+
+// removeDraft deletes the draft once its answers were saved or dismissed
+func (m *model) removeDraft() {
+	if m.opts.dryRun {
+		return
+	}
+	path := draftFilePath(envOutputFilePath)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Warning: could not remove %s: %v\n", path, err)
+	}
+}
+
+// loadDraft reads the draft left by a cancelled run and works out the values it can resume:
+// those of keys still in the template and not set by --answers. Sealed secrets are opened
+// with the .env.enc passphrase; without it they are listed in draftMissing.
+func (m *model) loadDraft() {
+	path := draftFilePath(envOutputFilePath)
+	d, err := readDraft(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read your unfinished answers: %v\n", err)
+		return
+	}
+	if d == nil || d.Template != templateFilePath {
+		return
+	}
+	values := make(map[string]string, len(d.Values))
+	for key, value := range d.Values {
+		values[key] = value
+	}
+	missing := slices.Clone(d.Skipped)
+	if d.Secrets != nil {
+		passphrase, err := readPassphrase(m.opts.keyFile, false, false)
+		var secrets map[string]string
+		if err == nil {
+			secrets, err = openValues(d.Secrets, passphrase)
+		}
+		for key := range d.Secrets.Values {
+			if err != nil {
+				missing = append(missing, key)
+			} else {
+				values[key] = secrets[key]
+			}
+		}
+	}
+
+	m.draft, m.draftValues, m.draftMissing = d, make(map[string]string), nil
+	for _, envVar := range m.envVars {
+		if _, answered := m.opts.answers[envVar.Key]; answered {
+			continue
+		}
+		if value, ok := values[envVar.Key]; ok {
+			m.draftValues[envVar.Key] = value
+			m.draftKeys = append(m.draftKeys, envVar.Key)
+		} else if slices.Contains(missing, envVar.Key) {
+			m.draftMissing = append(m.draftMissing, envVar.Key)
+		}
+	}
+}
+
+// draftQuestion asks whether to resume the draft, with the keys it holds as the details
+func (m *model) draftQuestion() (question, details string) {
+	question = fmt.Sprintf("Resume %d unfinished answers from %s?", len(m.draftKeys), m.draft.Saved.Local().Format("Jan 2 15:04"))
+	details = strings.Join(m.draftKeys, ", ")
+	if len(m.draftMissing) > 0 {
+		details += fmt.Sprintf("\nNot restored: %s. Secrets need %s or --key-file.", strings.Join(m.draftMissing, ", "), passphraseEnvVar)
+	}
+	return question, details
+}
+
+// prepareDraftForm asks whether to resume the answers of a cancelled run. It reports false
+// when there is no draft or nothing in it applies to this template.
+func (m *model) prepareDraftForm() bool {
+	m.loadDraft()
+	if len(m.draftValues) == 0 {
+		return false
+	}
+	question, details := m.draftQuestion()
+	m.resumeDraft = true
+	draftField := huh.NewConfirm().
+		Title(question).
+		Description(details).
+		Affirmative("Resume").
+		Negative("Start over").
+		Value(&m.resumeDraft)
+
+	m.draftForm = huh.NewForm(
+		huh.NewGroup(draftField).Title("Unfinished setup"),
+	).WithTheme(m.theme()).WithKeyMap(m.keys.formKeyMap())
+	m.applyLayout()
+	return true
+}
+
+// resolveDraft applies the draft answers when the user chose to resume them, or removes the
+// draft, then moves on to the next step. A resumed main form has focus where the run stopped.
+func (m *model) resolveDraft() tea.Cmd {
+	m.draftForm = nil
+	if !m.resumeDraft {
+		m.removeDraft()
+		return m.showForm()
+	}
+	for _, key := range m.draftKeys {
+		m.setFieldValue(m.envVarIndex(key), m.draftValues[key])
+	}
+	cmd := m.showForm()
+	if m.form != nil && !m.choosingScope && !m.confirming {
+		if i := m.envVarIndex(m.draft.Field); i != -1 {
+			for position := indexOf(m.visibleFieldIndices(), i); position > 0; position-- {
+				m.form.NextField()
+			}
+		}
+	}
+	return cmd
+}
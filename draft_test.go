@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrafts(t *testing.T) {
+	defer func(n int) { pbkdf2Iterations = n }(pbkdf2Iterations)
+	pbkdf2Iterations = 1000
+
+	setupDir := func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "APP_NAME=app\nAPI_TOKEN= # @secret\nDB_HOST=localhost\n"})
+		t.Setenv(passphraseEnvVar, "")
+	}
+	typeText := func(m *model, text string) {
+		for _, r := range text {
+			m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+	// cancel types into the first two fields and quits with ctrl+c
+	cancel := func(t *testing.T) {
+		m := initialModel()
+		m.Init()
+		typeText(m, "-api")
+		m.form.NextField()
+		typeText(m, "t0ken")
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+		assert.True(t, m.aborted)
+	}
+
+	t.Run("secrets sealed with the passphrase", func(t *testing.T) {
+		setupDir(t)
+		t.Setenv(passphraseEnvVar, "hunter2")
+		cancel(t)
+
+		content, err := os.ReadFile(".env.draft")
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "t0ken")
+		d, err := readDraft(".env.draft")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"APP_NAME": "app-api"}, d.Values)
+		assert.Equal(t, "API_TOKEN", d.Field)
+		require.NotNil(t, d.Secrets)
+		assert.Empty(t, d.Skipped)
+
+		m := initialModel()
+		m.Init()
+		require.NotNil(t, m.draftForm)
+		assert.Contains(t, m.View(), "Resume 2 unfinished answers")
+		m.draftForm.State = huh.StateCompleted
+		m.Update(nil)
+		assert.Nil(t, m.draftForm)
+		assert.Equal(t, "app-api", m.fieldValue(0))
+		assert.Equal(t, "t0ken", m.fieldValue(1))
+		assert.Equal(t, 1, m.focusedFieldIndex(), "the form opens where the run stopped")
+		assert.Len(t, m.undoStack, 2, "resumed answers can be undone")
+
+		require.NoError(t, m.prepareForConfirmation())
+		require.NoError(t, m.save())
+		assert.NoFileExists(t, ".env.draft", "a saved draft is removed")
+	})
+
+	t.Run("secrets left out without a passphrase", func(t *testing.T) {
+		setupDir(t)
+		cancel(t)
+
+		content, err := os.ReadFile(".env.draft")
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "t0ken")
+		d, err := readDraft(".env.draft")
+		require.NoError(t, err)
+		assert.Nil(t, d.Secrets)
+		assert.Equal(t, []string{"API_TOKEN"}, d.Skipped)
+
+		m := initialModel()
+		m.Init()
+		require.NotNil(t, m.draftForm)
+		assert.Contains(t, m.View(), "Not restored: API_TOKEN")
+	})
+
+	t.Run("start over", func(t *testing.T) {
+		setupDir(t)
+		cancel(t)
+
+		m := initialModel()
+		m.Init()
+		require.NotNil(t, m.draftForm)
+		m.resumeDraft = false
+		m.draftForm.State = huh.StateCompleted
+		m.Update(nil)
+		assert.Equal(t, "app", m.fieldValue(0))
+		assert.NoFileExists(t, ".env.draft")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+		assert.NoFileExists(t, ".env.draft", "quitting without edits leaves no draft")
+	})
+
+	t.Run("plain prompts", func(t *testing.T) {
+		setupDir(t)
+		cancel(t)
+
+		var out bytes.Buffer
+		require.NoError(t, runPlain(newModel(options{}), strings.NewReader("y\n\n\n\ny\n"), &out, false))
+		assert.Contains(t, out.String(), "Resume 1 unfinished answers")
+		values, err := readExistingEnvFile(".env")
+		require.NoError(t, err)
+		assert.Equal(t, "app-api", values["APP_NAME"])
+		assert.NoFileExists(t, ".env.draft")
+	})
+
+	t.Run("not read by dry runs", func(t *testing.T) {
+		setupDir(t)
+		cancel(t)
+
+		m := newModel(options{dryRun: true})
+		m.Init()
+		assert.Nil(t, m.draftForm)
+		assert.FileExists(t, ".env.draft")
+	})
+}
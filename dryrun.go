@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dryRunReport is the document printed by --dry-run --output json
+type dryRunReport struct {
+	Template string   `json:"template"`
+	EnvFile  string   `json:"env_file"`
+	Services []string `json:"services,omitempty"` // Service directories with --recursive, each change names its file
+	Changes  []change `json:"changes"`
+	Checks   []string `json:"checks,omitempty"` // Results of the @check reachability checks
+}
+
+// runDryRun prints the changes a run would make if every prefilled value were accepted.
+// Secret values are redacted and nothing is written. Empty @required variables fail with errValidation.
+func runDryRun(m *model, out io.Writer, format string) error {
+	m.opts.dryRun = true
+	m.Init()
+	if m.err != nil {
+		return m.err
+	}
+	collected, err := m.collectValues()
+	if err != nil {
+		return err
+	}
+	changes := m.changeSet(collected)
+	m.runChecks()
+	missing, _ := m.validateAll(collected)
+	for i := range changes {
+		changes[i] = changes[i].redacted()
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		report := dryRunReport{Template: templateFilePath, EnvFile: envOutputFilePath, Changes: append([]change{}, changes...), Checks: m.checkLines()}
+		for _, s := range m.services {
+			report.Services = append(report.Services, s.Name)
+		}
+		err = enc.Encode(report)
+	case "text":
+		if len(changes) == 0 {
+			_, err = fmt.Fprintln(out, noChangesMessage())
+		} else {
+			_, err = fmt.Fprintln(out, formatChanges(changes))
+		}
+		if checks := m.checkLines(); err == nil && len(checks) > 0 {
+			_, err = fmt.Fprintf(out, "Checks:\n  %s\n", strings.Join(checks, "\n  "))
+		}
+	default:
+		return fmt.Errorf("unknown output format %q, expected text or json", format)
+	}
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing required variables: %s", errValidation, strings.Join(missing, ", "))
+	}
+	return nil
+}
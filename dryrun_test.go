@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDryRun(t *testing.T) {
+	chdirTemp(t, map[string]string{
+		".env.example": "DB_HOST=localhost\nDB_PASSWORD=changeme\nAPP_NAME=app",
+		".env":         "DB_HOST=db.local\nDB_PASSWORD=old\nLEGACY_TOKEN=abc\n",
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		m := newModel(options{answers: map[string]string{"DB_PASSWORD": "new", "APP_NAME": "shop"}})
+		require.NoError(t, runDryRun(m, &out, "json"))
+
+		var raw struct {
+			Changes []map[string]any `json:"changes"`
+		}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &raw))
+		assert.Equal(t, []map[string]any{
+			{"key": "DB_PASSWORD", "kind": "changed", "old": "********", "new": "********", "secret": true, "source": "answers"},
+			{"key": "APP_NAME", "kind": "added", "old": "", "new": "shop", "secret": false, "source": "answers"},
+			{"key": "LEGACY_TOKEN", "kind": "removed", "old": "********", "new": "", "secret": true},
+		}, raw.Changes)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runDryRun(newModel(options{}), &out, "text"))
+		assert.Equal(t, "- Removed: LEGACY_TOKEN (was \"********\")\n", out.String())
+	})
+
+	t.Run("passwords in URLs are redacted", func(t *testing.T) {
+		var out bytes.Buffer
+		m := newModel(options{answers: map[string]string{"DB_HOST": "postgres://app:hunter2@db:5432/app"}})
+		require.NoError(t, runDryRun(m, &out, "json"))
+		assert.Contains(t, out.String(), `"new": "postgres://app:xxxxx@db:5432/app"`)
+		assert.NotContains(t, out.String(), "hunter2")
+	})
+
+	t.Run("nothing is written", func(t *testing.T) {
+		content, err := os.ReadFile(".env")
+		require.NoError(t, err)
+		assert.Equal(t, "DB_HOST=db.local\nDB_PASSWORD=old\nLEGACY_TOKEN=abc\n", string(content))
+		_, err = os.Stat(stampFilePath(".env"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
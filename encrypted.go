@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	encryptedFilePath    = ".env.enc"
+	encryptedHeader      = "# setup-env encrypted v1"
+	encryptedValuePrefix = "enc:v1:"
+	passphraseEnvVar     = "SETUP_ENV_PASSPHRASE"
+	saltSize             = 16
+)
+
+// pbkdf2Iterations is the work factor for new files, and the least a file may ask for.
+// Tests lower it.
+var pbkdf2Iterations = 600000
+
+// maxPBKDF2Iterations bounds the work factor read from a file, so a crafted header cannot
+// keep the tool busy for hours
+const maxPBKDF2Iterations = 10_000_000
+
+var (
+	errNoPassphrase = errors.New("no passphrase for " + encryptedFilePath)
+	errDecrypt      = errors.New("wrong passphrase or tampered value")
+)
+
+// encryptedFile is a .env.enc file: a dotenv file whose values are encrypted one by one,
+// so a diff of the file still shows which keys changed.
+type encryptedFile struct {
+	Salt       []byte            `json:"salt"`
+	Iterations int               `json:"iterations"`
+	Values     map[string]string `json:"values"` // Sealed values, "enc:v1:" followed by the nonce and ciphertext
+}
+
+// readEncryptedFile reads a .env.enc file. A missing file yields nil and no error.
+func readEncryptedFile(path string) (*encryptedFile, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && header == "" {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	if !strings.HasPrefix(header, encryptedHeader) {
+		return nil, fmt.Errorf("%s is not a setup-env encrypted file", path)
+	}
+	f := &encryptedFile{}
+	for _, field := range strings.Fields(strings.TrimPrefix(header, encryptedHeader)) {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "iterations":
+			f.Iterations, err = strconv.Atoi(value)
+		case "salt":
+			f.Salt, err = base64.RawStdEncoding.DecodeString(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in the header of %s: %w", name, path, err)
+		}
+	}
+	if f.Iterations <= 0 || len(f.Salt) == 0 {
+		return nil, fmt.Errorf("the header of %s lacks the salt or iterations", path)
+	}
+	if err := checkIterations(f.Iterations); err != nil {
+		return nil, fmt.Errorf("the header of %s: %w", path, err)
+	}
+
+	if f.Values, err = readExistingEnvFile(path); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// writeEncryptedFile writes f to path, one sealed value per line sorted by key
+func writeEncryptedFile(path string, f *encryptedFile) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s kdf=pbkdf2-sha256 iterations=%d salt=%s\n", encryptedHeader, f.Iterations, base64.RawStdEncoding.EncodeToString(f.Salt))
+	if err := writeEnvValues(&b, f.Values); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// checkIterations refuses work factors weaker than the one used for new files, or too
+// large to derive a key in reasonable time
+func checkIterations(iterations int) error {
+	if iterations < pbkdf2Iterations || iterations > maxPBKDF2Iterations {
+		return fmt.Errorf("%d PBKDF2 iterations is outside the accepted range of %d to %d", iterations, pbkdf2Iterations, maxPBKDF2Iterations)
+	}
+	return nil
+}
+
+// cipher derives the value cipher for this file from a passphrase
+func (f *encryptedFile) cipher(passphrase []byte) (cipher.AEAD, error) {
+	if err := checkIterations(f.Iterations); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(pbkdf2.Key(passphrase, f.Salt, f.Iterations, 32, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealValues encrypts values with AES-GCM. The key name is authenticated with each value,
+// so values cannot be swapped between keys. Values that did not change since previous
+// keep their ciphertext, which keeps diffs of the file small.
+func sealValues(values map[string]string, passphrase []byte, previous *encryptedFile) (*encryptedFile, error) {
+	f := &encryptedFile{Iterations: pbkdf2Iterations, Values: make(map[string]string, len(values))}
+	var old map[string]string
+	if previous != nil {
+		f.Salt, f.Iterations = previous.Salt, previous.Iterations
+		var err error
+		if old, err = openValues(previous, passphrase); err != nil {
+			return nil, err
+		}
+	} else {
+		f.Salt = make([]byte, saltSize)
+		if _, err := rand.Read(f.Salt); err != nil {
+			return nil, err
+		}
+	}
+
+	aead, err := f.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		if oldValue, ok := old[key]; ok && oldValue == value {
+			f.Values[key] = previous.Values[key]
+			continue
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		sealed := aead.Seal(nonce, nonce, []byte(value), []byte(key))
+		f.Values[key] = encryptedValuePrefix + base64.RawStdEncoding.EncodeToString(sealed)
+	}
+	return f, nil
+}
+
+// openValues decrypts every value of f
+func openValues(f *encryptedFile, passphrase []byte) (map[string]string, error) {
+	aead, err := f.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(f.Values))
+	for key, value := range f.Values {
+		encoded, ok := strings.CutPrefix(value, encryptedValuePrefix)
+		if !ok {
+			return nil, fmt.Errorf("%s in %s is not encrypted", key, encryptedFilePath)
+		}
+		sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("%s in %s is malformed", key, encryptedFilePath)
+		}
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt %s: %w", key, errDecrypt)
+		}
+		values[key] = string(plain)
+	}
+	return values, nil
+}
+
+// readPassphrase returns the secret for .env.enc: the contents of keyFile if set, then
+// $SETUP_ENV_PASSPHRASE, then a terminal prompt when prompt is set. New files ask twice.
+func readPassphrase(keyFile string, prompt, newFile bool) ([]byte, error) {
+	if keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key file: %w", err)
+		}
+		return []byte(strings.TrimRight(string(content), "\r\n")), nil
+	}
+	if passphrase := os.Getenv(passphraseEnvVar); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if !prompt {
+		return nil, errNoPassphrase
+	}
+
+	ask := func(question string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, question)
+		defer fmt.Fprintln(os.Stderr)
+		return term.ReadPassword(os.Stdin.Fd())
+	}
+	passphrase, err := ask(fmt.Sprintf("Passphrase for %s: ", encryptedFilePath))
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errNoPassphrase
+	}
+	if newFile {
+		repeated, err := ask("Repeat the passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if string(repeated) != string(passphrase) {
+			return nil, errors.New("the passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// loadEncryptedSource decrypts .env.enc for use as a prefill source.
+// It returns nil when there is no .env.enc or no passphrase was given.
+func loadEncryptedSource(path, keyFile string, prompt bool) (map[string]string, error) {
+	f, err := readEncryptedFile(path)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	passphrase, err := readPassphrase(keyFile, prompt, false)
+	if errors.Is(err, errNoPassphrase) {
+		fmt.Fprintf(os.Stderr, "Warning: %s found but not used, set %s or pass --key-file\n", path, passphraseEnvVar)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openValues(f, passphrase)
+}
+
+// runEncrypt implements `setup-env encrypt`, which writes .env.enc from a plain .env file
+func runEncrypt(args []string) int {
+	flags := flag.NewFlagSet("setup-env encrypt", flag.ContinueOnError)
+	keyFile := flags.String("key-file", "", "file holding the passphrase, instead of $"+passphraseEnvVar+" or a prompt")
+	in := flags.String("in", envOutputFilePath, "plain dotenv file to encrypt")
+	out := flags.String("out", encryptedFilePath, "encrypted file to write")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	values, err := readExistingEnvFile(*in)
+	if err == nil && len(values) == 0 {
+		err = fmt.Errorf("no values found in %s", *in)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+	previous, err := readEncryptedFile(*out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+	passphrase, err := readPassphrase(*keyFile, isInteractive(), previous == nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+	f, err := sealValues(values, passphrase, previous)
+	if err == nil {
+		err = writeEncryptedFile(*out, f)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitWriteFailed
+	}
+
+	changed := 0
+	for key, value := range f.Values {
+		if previous == nil || previous.Values[key] != value {
+			changed++
+		}
+	}
+	fmt.Printf("Encrypted %d values from %s to %s, %d changed\n", len(f.Values), *in, *out, changed)
+	return exitOK
+}
+
+// runDecrypt implements `setup-env decrypt`, which prints or writes the plain values of .env.enc
+func runDecrypt(args []string) int {
+	flags := flag.NewFlagSet("setup-env decrypt", flag.ContinueOnError)
+	keyFile := flags.String("key-file", "", "file holding the passphrase, instead of $"+passphraseEnvVar+" or a prompt")
+	in := flags.String("in", encryptedFilePath, "encrypted file to read")
+	out := flags.String("out", "", "plain dotenv file to write, standard output if not set")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	f, err := readEncryptedFile(*in)
+	if err == nil && f == nil {
+		err = fmt.Errorf("%s not found", *in)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+	passphrase, err := readPassphrase(*keyFile, isInteractive(), false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+	values, err := openValues(f, passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+
+	if *out == "" {
+		err = writeEnvValues(os.Stdout, values)
+	} else {
+		var b strings.Builder
+		if err = writeEnvValues(&b, values); err == nil {
+			err = os.WriteFile(*out, []byte(b.String()), 0600)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitWriteFailed
+	}
+	return exitOK
+}
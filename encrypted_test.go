@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// Test vectors from RFC 7914, section 11
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		got := pbkdf2.Key([]byte(tt.password), []byte(tt.salt), tt.iterations, 64, sha256.New)
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("pbkdf2.Key(%q, %q, %d) = %x, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestSealValues(t *testing.T) {
+	defer func(n int) { pbkdf2Iterations = n }(pbkdf2Iterations)
+	pbkdf2Iterations = 1000
+	passphrase := []byte("correct horse")
+	values := map[string]string{"DB_HOST": "db.staging", "DB_PASSWORD": "s3cret", "EMPTY": ""}
+
+	f, err := sealValues(values, passphrase, nil)
+	require.NoError(t, err)
+	for key, sealed := range f.Values {
+		assert.True(t, strings.HasPrefix(sealed, encryptedValuePrefix), key)
+		if values[key] != "" {
+			assert.NotContains(t, sealed, values[key])
+		}
+	}
+
+	t.Run("round trip through the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".env.enc")
+		require.NoError(t, writeEncryptedFile(path, f))
+		read, err := readEncryptedFile(path)
+		require.NoError(t, err)
+		opened, err := openValues(read, passphrase)
+		require.NoError(t, err)
+		assert.Equal(t, values, opened)
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		_, err := openValues(f, []byte("wrong"))
+		assert.ErrorIs(t, err, errDecrypt)
+	})
+
+	t.Run("values are bound to their key", func(t *testing.T) {
+		swapped := &encryptedFile{Salt: f.Salt, Iterations: f.Iterations, Values: map[string]string{
+			"DB_HOST": f.Values["DB_PASSWORD"],
+		}}
+		_, err := openValues(swapped, passphrase)
+		assert.ErrorIs(t, err, errDecrypt)
+	})
+
+	t.Run("unchanged values keep their ciphertext", func(t *testing.T) {
+		updated := map[string]string{"DB_HOST": "db.staging", "DB_PASSWORD": "n3w", "API_URL": "https://api"}
+		next, err := sealValues(updated, passphrase, f)
+		require.NoError(t, err)
+		assert.Equal(t, f.Values["DB_HOST"], next.Values["DB_HOST"])
+		assert.NotEqual(t, f.Values["DB_PASSWORD"], next.Values["DB_PASSWORD"])
+		assert.NotContains(t, next.Values, "EMPTY")
+		opened, err := openValues(next, passphrase)
+		require.NoError(t, err)
+		assert.Equal(t, updated, opened)
+
+		_, err = sealValues(updated, []byte("wrong"), f)
+		assert.ErrorIs(t, err, errDecrypt)
+	})
+}
+
+func TestReadEncryptedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	f, err := readEncryptedFile(filepath.Join(tmpDir, "missing.enc"))
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+
+	path := createTempFileForModel(t, tmpDir, "plain.enc", "DB_HOST=localhost\n")
+	_, err = readEncryptedFile(path)
+	assert.ErrorContains(t, err, "not a setup-env encrypted file")
+
+	for _, iterations := range []string{"1000", "2000000000"} {
+		path := createTempFileForModel(t, tmpDir, "iterations-"+iterations+".enc", encryptedHeader+" kdf=pbkdf2-sha256 iterations="+iterations+" salt=c2FsdHNhbHRzYWx0c2FsdA\nDB_HOST=enc:v1:AAAA\n")
+		_, err = readEncryptedFile(path)
+		assert.ErrorContains(t, err, "outside the accepted range", "iterations=%s", iterations)
+	}
+}
+
+func TestModelEncryptedSource(t *testing.T) {
+	defer func(n int) { pbkdf2Iterations = n }(pbkdf2Iterations)
+	pbkdf2Iterations = 1000
+
+	chdirTemp(t, map[string]string{
+		".env.example": "DB_HOST=localhost\nAPI_KEY=\nAPP_NAME=app",
+		".env":         "DB_HOST=db.local\n",
+		"team.key":     "correct horse\n",
+	})
+	keyFile := "team.key"
+
+	f, err := sealValues(map[string]string{"DB_HOST": "db.staging", "API_KEY": "k-123"}, []byte("correct horse"), nil)
+	require.NoError(t, err)
+	require.NoError(t, writeEncryptedFile(encryptedFilePath, f))
+
+	encrypted, err := loadEncryptedSource(encryptedFilePath, keyFile, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_HOST": "db.staging", "API_KEY": "k-123"}, encrypted)
+
+	m := newModel(options{encrypted: encrypted, prefillOrder: withEncrypted(nil)})
+	m.Init()
+	require.NoError(t, m.err)
+	assert.Equal(t, "db.local", m.fieldValue(0), ".env takes priority over .env.enc by default")
+	assert.Equal(t, "k-123", m.fieldValue(1))
+	assert.Equal(t, sourceEncrypted, m.sources[1])
+	assert.Equal(t, "API_KEY  [from .env.enc]", m.titles[1])
+
+	t.Run("no passphrase skips the source", func(t *testing.T) {
+		t.Setenv(passphraseEnvVar, "")
+		encrypted, err := loadEncryptedSource(encryptedFilePath, "", false)
+		assert.NoError(t, err)
+		assert.Nil(t, encrypted)
+	})
+}
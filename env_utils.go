@@ -5,24 +5,109 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 )
 
 var envOutputFilePath = ".env" // For testability
 
-// readEnvVarsFromFile reads .env.example and parses it into EnvVar structs
+// templateFilePath is the template read by every command, set from the template setting
+var templateFilePath = ".env.example"
+
+// templateExtends are base templates every template builds on, set from the extends setting.
+// loadSettings has already resolved them against the directory of the config file.
+var templateExtends []string
+
+// includeDirective pulls the variables of another template into a template at its position
+const includeDirective = "#@include "
+
+// readEnvVarsFromFile reads .env.example, a local file or an http(s) URL, and parses it into
+// EnvVar structs, resolving #@include directives and the extends setting
 func readEnvVarsFromFile(filePath string) ([]EnvVar, error) {
-	exampleFile, err := os.Open(filePath)
+	return (&templateReader{}).readAll(filePath)
+}
+
+// readCachedEnvVars reads a template like readEnvVarsFromFile, but takes template URLs from
+// the cache only and never goes to the network
+func readCachedEnvVars(filePath string) ([]EnvVar, error) {
+	return (&templateReader{cacheOnly: true}).readAll(filePath)
+}
+
+// readAll reads a template with its includes and the templates it extends
+func (r *templateReader) readAll(filePath string) ([]EnvVar, error) {
+	envVars, err := r.read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	base := []EnvVar{}
+	for _, extends := range templateExtends {
+		included, err := r.read(extends)
+		if err != nil {
+			return nil, fmt.Errorf("extends %s: %w", extends, err)
+		}
+		base = mergeEnvVars(base, included)
+	}
+	return mergeEnvVars(base, envVars), nil
+}
+
+// templateReader follows includes and remembers the chain of files being read to detect cycles
+type templateReader struct {
+	chain     []string
+	cacheOnly bool // Read template URLs from the cache, see cachedTemplate
+}
+
+func (r *templateReader) read(filePath string) ([]EnvVar, error) {
+	abs, source := filePath, filePath
+	if isTemplateURL(filePath) {
+		fetch := fetchTemplate
+		if r.cacheOnly {
+			fetch = cachedTemplate
+		}
+		var err error
+		if source, err = fetch(filePath); err != nil {
+			return nil, err
+		}
+		abs, _, _ = strings.Cut(filePath, "#")
+	} else {
+		var err error
+		if abs, err = filepath.Abs(filePath); err != nil {
+			return nil, err
+		}
+	}
+	for i, parent := range r.chain {
+		if parent == abs {
+			cycle := append(append([]string{}, r.chain[i:]...), abs)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	r.chain = append(r.chain, abs)
+	defer func() { r.chain = r.chain[:len(r.chain)-1] }()
+
+	exampleFile, err := os.Open(source)
 	if err != nil {
 		return nil, err
 	}
 	defer exampleFile.Close()
 
-	var envVars []EnvVar
+	envVars := []EnvVar{}
 	scanner := bufio.NewScanner(exampleFile)
-	for scanner.Scan() {
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
 		line := scanner.Text()
 		lineForParsing := strings.TrimSpace(line)
+		if include, ok := strings.CutPrefix(lineForParsing, includeDirective); ok {
+			includePath, err := resolveInclude(filePath, strings.TrimSpace(include))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", filePath, lineNumber, err)
+			}
+			included, err := r.read(includePath)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", filePath, lineNumber, err)
+			}
+			envVars = mergeEnvVars(envVars, included)
+			continue
+		}
 		if lineForParsing == "" || strings.HasPrefix(lineForParsing, "#") {
 			continue
 		}
@@ -48,7 +133,17 @@ func readEnvVarsFromFile(filePath string) ([]EnvVar, error) {
 				}
 			}
 			if key != "" {
-				envVars = append(envVars, EnvVar{Key: key, Description: description, ExampleValue: exampleValue})
+				envVar := EnvVar{Key: key, ExampleValue: exampleValue}
+				var annotations map[string]string
+				envVar.Description, annotations = parseAnnotations(description)
+				_, envVar.Required = annotations["required"]
+				_, envVar.Secret = annotations["secret"]
+				envVar.Check = annotations["check"]
+				envVar.Type = strings.ToLower(annotations["type"])
+				if envVar.Type != "" && !validTypes[envVar.Type] {
+					return nil, fmt.Errorf("%s:%d: unknown @type=%s for %s, expected url, dsn, path, file or dir", filePath, lineNumber, envVar.Type, key)
+				}
+				envVars = mergeEnvVars(envVars, []EnvVar{envVar})
 			}
 		}
 	}
@@ -58,40 +153,117 @@ func readEnvVarsFromFile(filePath string) ([]EnvVar, error) {
 	return envVars, nil
 }
 
+// mergeEnvVars appends overrides to base. A key already in base keeps its position and takes
+// the non-empty example value and description of the override. @required and @secret can be
+// added by an override but never dropped, so a child cannot expose an inherited secret.
+func mergeEnvVars(base, overrides []EnvVar) []EnvVar {
+	for _, override := range overrides {
+		i := slices.IndexFunc(base, func(envVar EnvVar) bool { return envVar.Key == override.Key })
+		if i == -1 {
+			base = append(base, override)
+			continue
+		}
+		if override.ExampleValue != "" {
+			base[i].ExampleValue = override.ExampleValue
+		}
+		if override.Description != "" {
+			base[i].Description = override.Description
+		}
+		base[i].Required = base[i].Required || override.Required
+		base[i].Secret = base[i].Secret || override.Secret
+		if override.Check != "" {
+			base[i].Check = override.Check
+		}
+		if override.Type != "" {
+			base[i].Type = override.Type
+		}
+	}
+	return base
+}
+
+// parseAnnotations splits @name and @name=value tokens out of a description.
+// For example "Database host @required" yields "Database host" and {"required": ""}.
+func parseAnnotations(description string) (string, map[string]string) {
+	var words []string
+	var annotations map[string]string
+	for _, word := range strings.Fields(description) {
+		if len(word) < 2 || word[0] != '@' {
+			words = append(words, word)
+			continue
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		name, value, _ := strings.Cut(word[1:], "=")
+		annotations[strings.ToLower(name)] = value
+	}
+	if annotations == nil {
+		return description, nil
+	}
+	return strings.Join(words, " "), annotations
+}
+
+// containsKey reports whether the template defines the given key
+func containsKey(envVars []EnvVar, key string) bool {
+	for _, envVar := range envVars {
+		if envVar.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
 // writeEnvFile creates or overwrites the .env file with the given values
 func writeEnvFile(values map[string]string) error {
-	envFile, err := os.Create(envOutputFilePath)
+	return writeEnvFileTo(envOutputFilePath, values)
+}
+
+// writeEnvFileTo creates or overwrites the dotenv file at path with the given values
+func writeEnvFileTo(path string, values map[string]string) error {
+	envFile, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer envFile.Close()
 
-	for key, value := range values {
-		needsQuoting := false
-		if value == "" {
-			needsQuoting = true
-		} else {
-			if strings.ContainsAny(value, " #=\"$\\`\n\r") {
-				needsQuoting = true
-			}
-		}
-		var outputValue string
-		if needsQuoting {
-			escaped := strings.ReplaceAll(value, `\`, `\\`)
-			escaped = strings.ReplaceAll(escaped, `"`, `\"`)
-			escaped = strings.ReplaceAll(escaped, "\n", `\n`)
-			escaped = strings.ReplaceAll(escaped, "\r", `\r`)
-			outputValue = `"` + escaped + `"`
-		} else {
-			outputValue = value
-		}
-		if _, err := envFile.WriteString(fmt.Sprintf("%s=%s\n", key, outputValue)); err != nil {
+	return writeEnvValues(envFile, values)
+}
+
+// writeEnvValues writes values in dotenv format, sorted by key
+func writeEnvValues(w io.Writer, values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", key, formatEnvValue(values[key])); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// formatEnvValue quotes and escapes a value when it is empty or contains special characters
+func formatEnvValue(value string) string {
+	needsQuoting := false
+	if value == "" {
+		needsQuoting = true
+	} else {
+		if strings.ContainsAny(value, " #=\"$\\`\n\r") {
+			needsQuoting = true
+		}
+	}
+	if !needsQuoting {
+		return value
+	}
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	escaped = strings.ReplaceAll(escaped, "\n", `\n`)
+	escaped = strings.ReplaceAll(escaped, "\r", `\r`)
+	return `"` + escaped + `"`
+}
+
 // readExistingEnvFile reads an existing .env file and returns its key-value pairs
 func readExistingEnvFile(filePath string) (map[string]string, error) {
 	values := make(map[string]string)
@@ -74,6 +74,14 @@ KEY_WITH_EQUALS=
 			},
 			expectError: false,
 		},
+		{
+			name:        "annotations are stripped from the description",
+			fileContent: `API_TOKEN= # Token for the API @required`,
+			expectedVars: []EnvVar{
+				{Key: "API_TOKEN", Description: "Token for the API", ExampleValue: "", Required: true},
+			},
+			expectError: false,
+		},
 		{
 			name:        "value with internal quotes not at ends",
 			fileContent: `KEY_INTERNAL_QUOTE=abc"def # description`,
@@ -128,6 +136,149 @@ KEY_WITH_EQUALS=
 	}
 }
 
+func TestReadEnvVarsFromFileIncludes(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("shared/.env.common", "LOG_LEVEL=info # Log verbosity\n#@include .env.otel\n")
+	write("shared/.env.otel", "OTEL_ENDPOINT=http://localhost:4318 # Collector @required\nOTEL_TOKEN= # @secret\n")
+	write("base/.env.base", "REGION=eu-west-1 # Cloud region\n")
+
+	t.Run("includes with overrides", func(t *testing.T) {
+		child := write("api/.env.example", "PORT=8080\n#@include ../shared/.env.common\nLOG_LEVEL=debug\nOTEL_TOKEN= # Token for the api collector\n")
+		vars, err := readEnvVarsFromFile(child)
+		if err != nil {
+			t.Fatalf("Did not expect an error, but got: %v", err)
+		}
+		expected := []EnvVar{
+			{Key: "PORT", ExampleValue: "8080"},
+			{Key: "LOG_LEVEL", Description: "Log verbosity", ExampleValue: "debug"},
+			{Key: "OTEL_ENDPOINT", Description: "Collector", ExampleValue: "http://localhost:4318", Required: true},
+			{Key: "OTEL_TOKEN", Description: "Token for the api collector", Secret: true},
+		}
+		if !reflect.DeepEqual(vars, expected) {
+			t.Errorf("Expected vars:\n%v\nGot vars:\n%v", expected, vars)
+		}
+	})
+
+	t.Run("extends setting", func(t *testing.T) {
+		templateExtends = []string{filepath.Join(tmpDir, "base/.env.base")}
+		defer func() { templateExtends = nil }()
+		child := write("web/.env.example", "PORT=3000\nREGION=us-east-1\n")
+		vars, err := readEnvVarsFromFile(child)
+		if err != nil {
+			t.Fatalf("Did not expect an error, but got: %v", err)
+		}
+		expected := []EnvVar{
+			{Key: "REGION", Description: "Cloud region", ExampleValue: "us-east-1"},
+			{Key: "PORT", ExampleValue: "3000"},
+		}
+		if !reflect.DeepEqual(vars, expected) {
+			t.Errorf("Expected vars:\n%v\nGot vars:\n%v", expected, vars)
+		}
+	})
+
+	t.Run("extends from the config file, run in a subdirectory", func(t *testing.T) {
+		chdirTemp(t, map[string]string{
+			configFileName:        "extends: [base/.env.base]\n",
+			"base/.env.base":      "REGION=eu-west-1 # Cloud region\n",
+			"worker/.env.example": "QUEUE=jobs\n",
+		})
+		if err := os.Chdir("worker"); err != nil {
+			t.Fatal(err)
+		}
+
+		s, _, err := loadSettings(".", settings{})
+		if err != nil {
+			t.Fatalf("Did not expect an error, but got: %v", err)
+		}
+		templateExtends = s.Extends
+		defer func() { templateExtends = nil }()
+		vars, err := readEnvVarsFromFile(s.Template)
+		if err != nil {
+			t.Fatalf("Did not expect an error, but got: %v", err)
+		}
+		expected := []EnvVar{
+			{Key: "REGION", Description: "Cloud region", ExampleValue: "eu-west-1"},
+			{Key: "QUEUE", ExampleValue: "jobs"},
+		}
+		if !reflect.DeepEqual(vars, expected) {
+			t.Errorf("Expected vars:\n%v\nGot vars:\n%v", expected, vars)
+		}
+	})
+
+	errorTests := []struct {
+		name           string
+		files          map[string]string
+		expectedErrMsg string
+	}{
+		{
+			name:           "cycle",
+			files:          map[string]string{"cycle/a.env": "A=1\n#@include b.env\n", "cycle/b.env": "#@include a.env\n"},
+			expectedErrMsg: "include cycle: " + filepath.Join(tmpDir, "cycle/a.env") + " -> " + filepath.Join(tmpDir, "cycle/b.env") + " -> " + filepath.Join(tmpDir, "cycle/a.env"),
+		},
+		{
+			name:           "self include",
+			files:          map[string]string{"cycle/a.env": "#@include ./a.env\n"},
+			expectedErrMsg: "include cycle",
+		},
+		{
+			name:           "missing include",
+			files:          map[string]string{"cycle/a.env": "A=1\n#@include missing.env\n"},
+			expectedErrMsg: ":2: open " + filepath.Join(tmpDir, "cycle/missing.env"),
+		},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, content := range tt.files {
+				write(name, content)
+			}
+			_, err := readEnvVarsFromFile(filepath.Join(tmpDir, "cycle/a.env"))
+			if err == nil {
+				t.Fatalf("Expected an error, but got nil")
+			}
+			if !strings.Contains(err.Error(), tt.expectedErrMsg) {
+				t.Errorf("Expected error message to contain '%s', but got '%s'", tt.expectedErrMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	tests := []struct {
+		name                string
+		description         string
+		expectedDescription string
+		expectedAnnotations map[string]string
+	}{
+		{"no annotations", "Database host", "Database host", nil},
+		{"flag annotation", "Database host @required", "Database host", map[string]string{"required": ""}},
+		{"value annotation", "@Type=url Connection string", "Connection string", map[string]string{"type": "url"}},
+		{"email is not an annotation", "Contact ops@example.com", "Contact ops@example.com", nil},
+		{"lone at sign", "Use @ for mentions", "Use @ for mentions", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			description, annotations := parseAnnotations(tt.description)
+			if description != tt.expectedDescription {
+				t.Errorf("Expected description %q, got %q", tt.expectedDescription, description)
+			}
+			if !reflect.DeepEqual(annotations, tt.expectedAnnotations) {
+				t.Errorf("Expected annotations %v, got %v", tt.expectedAnnotations, annotations)
+			}
+		})
+	}
+}
+
 func TestWriteEnvFile(t *testing.T) {
 	tests := []struct {
 		name           string
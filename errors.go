@@ -0,0 +1,49 @@
+package main
+
+import "errors"
+
+// Sentinel errors that end a run. Errors wrap them with %w and main maps them to exit statuses.
+var (
+	errTemplateMissing = errors.New("file not found")
+	errTemplateEmpty   = errors.New("template is empty")
+	errAborted         = errors.New("aborted by user")
+	errValidation      = errors.New("validation failed")
+	errWriteFailed     = errors.New("error writing .env file")
+	errTrackedFile     = errors.New("refusing to write secrets into a file tracked by git")
+)
+
+// Exit statuses of setup-env. They are documented in the README, keep both in sync.
+const (
+	exitOK              = 0
+	exitError           = 1 // Any failure without a more specific status
+	exitUsage           = 2 // Invalid flags or arguments
+	exitTemplateMissing = 3
+	exitTemplateEmpty   = 4
+	exitValidation      = 5
+	exitWriteFailed     = 6
+	exitTrackedFile     = 7
+	exitOutOfDate       = 8   // --quiet-if-current found new or changed variables
+	exitAborted         = 130 // Cancelled, or the changes were discarded
+)
+
+// exitCode returns the exit status for the error that ended a run
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errTemplateMissing):
+		return exitTemplateMissing
+	case errors.Is(err, errTemplateEmpty):
+		return exitTemplateEmpty
+	case errors.Is(err, errAborted):
+		return exitAborted
+	case errors.Is(err, errValidation):
+		return exitValidation
+	case errors.Is(err, errWriteFailed):
+		return exitWriteFailed
+	case errors.Is(err, errTrackedFile):
+		return exitTrackedFile
+	default:
+		return exitError
+	}
+}
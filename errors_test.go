@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, exitOK},
+		{"template missing", fmt.Errorf("Error reading .env.example: %w.", errTemplateMissing), exitTemplateMissing},
+		{"template empty", fmt.Errorf("wrapped: %w", errTemplateEmpty), exitTemplateEmpty},
+		{"aborted", errAborted, exitAborted},
+		{"validation", fmt.Errorf("%w: DB_HOST is required", errValidation), exitValidation},
+		{"write failed", fmt.Errorf("%w: %w", errWriteFailed, os.ErrPermission), exitWriteFailed},
+		{"other", errors.New("boom"), exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelExitErr(t *testing.T) {
+	t.Run("missing template", func(t *testing.T) {
+		chdirTemp(t, nil)
+
+		m := initialModel()
+		m.Init()
+		assert.ErrorIs(t, m.exitErr(), errTemplateMissing)
+		assert.Contains(t, m.exitErr().Error(), "Error reading .env.example")
+	})
+
+	t.Run("empty template", func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "# only comments\n"})
+
+		m := initialModel()
+		m.Init()
+		assert.ErrorIs(t, m.exitErr(), errTemplateEmpty)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "KEY=value\n"})
+
+		m := initialModel()
+		m.Init()
+		require.NoError(t, m.exitErr())
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+		assert.ErrorIs(t, m.exitErr(), errAborted)
+	})
+
+	t.Run("write failure", func(t *testing.T) {
+		tmpDir := chdirTemp(t, nil)
+		originalOutput := envOutputFilePath
+		envOutputFilePath = tmpDir + "/missing-dir/.env"
+		defer func() { envOutputFilePath = originalOutput }()
+
+		m := initialModel()
+		err := m.actuallyWriteEnvFile(map[string]string{"KEY": "value"})
+		assert.ErrorIs(t, err, errWriteFailed)
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// resolveEnvironment resolves the values the exec subcommand passes to its child: every key in .env,
+// plus each template key resolved with the same prefill order the form uses.
+// It also returns the @required keys that resolved to an empty value.
+func resolveEnvironment(templatePath, envPath string, order []valueSource, encrypted map[string]string) (map[string]string, []string, error) {
+	envVars, err := readEnvVarsFromFile(templatePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	existing, err := readExistingEnvFile(envPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(order) == 0 {
+		order = defaultPrefillOrder
+	}
+	sources := prefillSources{sourceDotEnv: existing, sourceEnvironment: environValues(os.Environ()), sourceEncrypted: encrypted}
+
+	values := make(map[string]string, len(existing)+len(envVars))
+	for key, value := range existing {
+		values[key] = value
+	}
+	var missing []string
+	for _, envVar := range envVars {
+		value, _ := resolveInitialValue(envVar, order, sources)
+		values[envVar.Key] = value
+		if envVar.Required && value == "" {
+			missing = append(missing, envVar.Key)
+		}
+	}
+	return values, missing, nil
+}
+
+// mergeEnviron overrides os.Environ style entries with the given values
+func mergeEnviron(environ []string, values map[string]string) []string {
+	merged := make([]string, 0, len(environ)+len(values))
+	for _, entry := range environ {
+		key, _, _ := strings.Cut(entry, "=")
+		if _, ok := values[key]; !ok {
+			merged = append(merged, entry)
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		merged = append(merged, key+"="+values[key])
+	}
+	return merged
+}
+
+// runExec implements `setup-env exec -- command [args...]`. It returns the child's exit status.
+func runExec(args []string) int {
+	flags := flag.NewFlagSet("setup-env exec", flag.ContinueOnError)
+	prefillOrderFlag := flags.String("prefill-order", "", "comma separated prefill sources in priority order: dotenv, enc, env, example")
+	keyFile := flags.String("key-file", "", "file holding the passphrase for "+encryptedFilePath)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: setup-env exec [--prefill-order list] [--key-file path] -- command [args...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var order []valueSource
+	if *prefillOrderFlag != "" {
+		var err error
+		if order, err = parsePrefillOrder(*prefillOrderFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+			return exitUsage
+		}
+	} else if *keyFile != "" {
+		order = withEncrypted(order)
+	}
+
+	var encrypted map[string]string
+	if containsSource(order, sourceEncrypted) {
+		var err error
+		if encrypted, err = loadEncryptedSource(encryptedFilePath, *keyFile, isInteractive()); err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+			return exitError
+		}
+	}
+
+	values, missing, err := resolveEnvironment(templateFilePath, envOutputFilePath, order, encrypted)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "setup-env: missing required variables: %s. Run setup-env to set them.\n", strings.Join(missing, ", "))
+		return exitValidation
+	}
+
+	cmd := exec.Command(flags.Arg(0), flags.Args()[1:]...)
+	cmd.Env = mergeEnviron(os.Environ(), values)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return runForwardingSignals(cmd)
+}
+
+// runForwardingSignals runs cmd, relays termination signals to it and returns its exit status.
+// A child killed by a signal yields 128 plus the signal number, like a shell does.
+func runForwardingSignals(cmd *exec.Cmd) int {
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return 127
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return 1
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
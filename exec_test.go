@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveEnvironment(t *testing.T) {
+	tmpDir := t.TempDir()
+	templatePath := createTempFileForModel(t, tmpDir, ".env.example", "HOST=localhost\nPORT=5432\nTOKEN= # API token @required")
+	envPath := createTempFileForModel(t, tmpDir, ".env", "PORT=6543\nEXTRA=kept")
+
+	values, missing, err := resolveEnvironment(templatePath, envPath, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"HOST": "localhost", "PORT": "6543", "TOKEN": "", "EXTRA": "kept"}, values)
+	assert.Equal(t, []string{"TOKEN"}, missing)
+
+	t.Run("environment as a source", func(t *testing.T) {
+		t.Setenv("TOKEN", "from-shell")
+		values, missing, err := resolveEnvironment(templatePath, envPath, []valueSource{sourceDotEnv, sourceEnvironment, sourceExample}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "from-shell", values["TOKEN"])
+		assert.Empty(t, missing)
+	})
+
+	t.Run("missing template uses .env only", func(t *testing.T) {
+		values, missing, err := resolveEnvironment(tmpDir+"/missing.example", envPath, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"PORT": "6543", "EXTRA": "kept"}, values)
+		assert.Empty(t, missing)
+	})
+}
+
+func TestMergeEnviron(t *testing.T) {
+	merged := mergeEnviron([]string{"PATH=/bin", "PORT=1", "HOME=/root"}, map[string]string{"PORT": "2", "HOST": "h"})
+	assert.Equal(t, []string{"PATH=/bin", "HOME=/root", "HOST=h", "PORT=2"}, merged)
+}
+
+func TestRunExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	chdirTemp(t, map[string]string{".env.example": "GREETING=hello\nNAME= # @required", ".env": "NAME=world"})
+
+	t.Run("child sees the resolved values", func(t *testing.T) {
+		code := runExec([]string{"--", "sh", "-c", `test "$GREETING $NAME" = "hello world"`})
+		assert.Equal(t, 0, code)
+	})
+
+	t.Run("exit code passes through", func(t *testing.T) {
+		assert.Equal(t, 3, runExec([]string{"--", "sh", "-c", "exit 3"}))
+	})
+
+	t.Run("killed child reports the signal", func(t *testing.T) {
+		assert.Equal(t, 128+15, runExec([]string{"--", "sh", "-c", "kill -TERM $$"}))
+	})
+
+	t.Run("missing required variables fail before running", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(".env", []byte("NAME="), 0644))
+		defer os.WriteFile(".env", []byte("NAME=world"), 0644)
+		assert.Equal(t, exitValidation, runExec([]string{"--", "sh", "-c", "touch ran"}))
+		_, err := os.Stat("ran")
+		assert.True(t, os.IsNotExist(err), "The command must not run")
+	})
+
+	t.Run("unreadable .env fails before running", func(t *testing.T) {
+		require.NoError(t, os.Rename(".env", "env.bak"))
+		require.NoError(t, os.Mkdir(".env", 0755))
+		defer os.Rename("env.bak", ".env")
+		defer os.Remove(".env")
+		assert.Equal(t, exitError, runExec([]string{"--", "sh", "-c", "touch ran"}))
+		_, err := os.Stat("ran")
+		assert.True(t, os.IsNotExist(err), "The command must not run")
+	})
+
+	t.Run("usage errors", func(t *testing.T) {
+		assert.Equal(t, 2, runExec(nil))
+		assert.Equal(t, 127, runExec([]string{"--", "./does-not-exist"}))
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// filterScope narrows the main form to a subset of fields, on top of the search text
+type filterScope int
+
+const (
+	scopeAll filterScope = iota
+	scopeEmpty
+	scopeEdited
+	scopeRequired
+)
+
+func (s filterScope) String() string {
+	switch s {
+	case scopeEmpty:
+		return "empty"
+	case scopeEdited:
+		return "edited"
+	case scopeRequired:
+		return "required"
+	default:
+		return "all"
+	}
+}
+
+func (s filterScope) next() filterScope {
+	return (s + 1) % (scopeRequired + 1)
+}
+
+// fuzzyMatch reports whether all runes of pattern appear in s in order, ignoring case
+func fuzzyMatch(pattern, s string) bool {
+	pattern = strings.ToLower(pattern)
+	s = strings.ToLower(s)
+	for _, r := range pattern {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		idx := strings.IndexRune(s, r)
+		if idx == -1 {
+			return false
+		}
+		s = s[idx+len(string(r)):]
+	}
+	return true
+}
+
+func newFilterInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Search: "
+	input.Placeholder = "key or description"
+	return input
+}
+
+// fieldValue returns the current value of the field at index i
+func (m *model) fieldValue(i int) string {
+	val, _ := m.fields[i].GetValue().(string)
+	return val
+}
+
+// matchesFilter reports whether the field at index i passes the search text and scope
+func (m *model) matchesFilter(i int) bool {
+	envVar := m.envVars[i]
+	switch m.filterScope {
+	case scopeEmpty:
+		if m.fieldValue(i) != "" {
+			return false
+		}
+	case scopeEdited:
+		if m.fieldValue(i) == m.initialValues[i] {
+			return false
+		}
+	case scopeRequired:
+		if !envVar.Required {
+			return false
+		}
+	}
+	query := m.filterInput.Value()
+	return query == "" || fuzzyMatch(query, envVar.Key) || fuzzyMatch(query, envVar.Description)
+}
+
+// filterActive reports whether the search text or scope hides any fields
+func (m *model) filterActive() bool {
+	return m.filterInput.Value() != "" || m.filterScope != scopeAll
+}
+
+// applyFilter rebuilds the main form with the fields matching the current filter.
+// When nothing matches the current form is kept so the user never ends up with an empty group.
+func (m *model) applyFilter() tea.Cmd {
+	indices := m.visibleFieldIndices()
+	m.filterMatches = len(indices)
+	if len(indices) == 0 {
+		return nil
+	}
+	if focused := m.form.GetFocusedField(); focused != nil {
+		focused.Blur()
+	}
+	m.buildMainForm()
+	return m.form.Init()
+}
+
+// updateFilter handles key presses while the search bar is open
+func (m *model) updateFilter(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, m.keys.FilterApply):
+		m.filtering = false
+		m.filterInput.Blur()
+		return nil
+	case key.Matches(msg, m.keys.FilterClear):
+		m.filtering = false
+		m.filterInput.Blur()
+		m.filterInput.SetValue("")
+		m.filterScope = scopeAll
+		return m.applyFilter()
+	case key.Matches(msg, m.keys.FilterScope):
+		m.filterScope = m.filterScope.next()
+		return m.applyFilter()
+	}
+
+	var cmd tea.Cmd
+	before := m.filterInput.Value()
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	if m.filterInput.Value() != before {
+		return tea.Batch(cmd, m.applyFilter())
+	}
+	return cmd
+}
+
+// filterView renders the search bar, or a short hint when it is closed
+func (m *model) filterView() string {
+	if m.filtering {
+		status := fmt.Sprintf("%d of %d", m.filterMatches, len(m.fields))
+		if m.filterMatches == 0 {
+			status = "no matches"
+		}
+		return fmt.Sprintf("%s  [show: %s]  %s\n%s", m.filterInput.View(), m.filterScope, status,
+			strings.Join([]string{helpText(m.keys.FilterScope), helpText(m.keys.FilterApply), helpText(m.keys.FilterClear)}, " • "))
+	}
+	if m.filterActive() {
+		filter := fmt.Sprintf("%s fields", m.filterScope)
+		if query := m.filterInput.Value(); query != "" {
+			filter = fmt.Sprintf("%s matching %q", filter, query)
+		}
+		return fmt.Sprintf("Showing %s (%d of %d) • %s to change", filter, m.filterMatches, len(m.fields), m.keys.Filter.Help().Key)
+	}
+	help := []string{helpText(m.keys.Filter), helpText(m.keys.ResetDefault), helpText(m.keys.RestoreOriginal)}
+	if i := m.focusedFieldIndex(); i != -1 && hasParts(m.envVars[i]) {
+		help = append(help, helpText(m.keys.EditParts))
+	} else if i != -1 && isPathType(m.envVars[i]) {
+		help = append(help, helpText(m.keys.Browse))
+	}
+	if len(m.undoStack) > 0 {
+		help = append(help, helpText(m.keys.Undo))
+	}
+	help = append(help, helpText(m.keys.ShowChanges), helpText(m.keys.ShowHelp))
+	if m.historyStatus != "" {
+		help = append([]string{m.historyStatus}, help...)
+	}
+	return lipgloss.NewStyle().Width(m.formWidth()).Render(strings.Join(help, " • "))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"", "DB_HOST", true},
+		{"dbh", "DB_HOST", true},
+		{"host", "DB_HOST", true},
+		{"db host", "DB_HOST", true},
+		{"hostdb", "DB_HOST", false},
+		{"pwd", "Database password", true},
+		{"xyz", "DB_HOST", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, fuzzyMatch(tt.pattern, tt.s), "fuzzyMatch(%q, %q)", tt.pattern, tt.s)
+	}
+}
+
+func TestModelFilter(t *testing.T) {
+	setupModel := func(t *testing.T) *model {
+		chdirTemp(t, map[string]string{".env.example": "DB_HOST=localhost # Database host\nDB_PASSWORD= # Database password @required\nAPP_NAME=app # Application name"})
+
+		m := initialModel()
+		_ = m.Init()
+		require.Nil(t, m.err)
+		return m
+	}
+	typeText := func(m *model, text string) {
+		for _, r := range text {
+			m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+
+	t.Run("search narrows the form to matching fields", func(t *testing.T) {
+		m := setupModel(t)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlF})
+		require.True(t, m.filtering, "ctrl+f should open the search bar")
+
+		typeText(m, "database")
+		assert.Equal(t, []int{0, 1}, m.visibleFieldIndices())
+		assert.Equal(t, 2, m.filterMatches)
+		assert.Contains(t, m.View(), "2 of 3")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		assert.False(t, m.filtering, "enter should close the search bar")
+		assert.Equal(t, huh.StateNormal, m.form.State, "enter in the search bar must not submit the form")
+		assert.Contains(t, m.View(), `matching "database"`)
+	})
+
+	t.Run("no matches keeps the current form", func(t *testing.T) {
+		m := setupModel(t)
+
+		form := m.form
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlF})
+		typeText(m, "zzz")
+		assert.Equal(t, 0, m.filterMatches)
+		assert.Same(t, form, m.form)
+		assert.Contains(t, m.View(), "no matches")
+	})
+
+	t.Run("scope cycles through empty, edited and required", func(t *testing.T) {
+		m := setupModel(t)
+
+		edited := "changed"
+		m.fields[2].(*huh.Input).Value(&edited)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlF})
+		m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		assert.Equal(t, scopeEmpty, m.filterScope)
+		assert.Equal(t, []int{1}, m.visibleFieldIndices())
+
+		m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		assert.Equal(t, scopeEdited, m.filterScope)
+		assert.Equal(t, []int{2}, m.visibleFieldIndices())
+
+		m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		assert.Equal(t, scopeRequired, m.filterScope)
+		assert.Equal(t, []int{1}, m.visibleFieldIndices())
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.False(t, m.filtering)
+		assert.Equal(t, scopeAll, m.filterScope)
+		assert.Equal(t, 3, m.filterMatches)
+		assert.Equal(t, huh.StateNormal, m.form.State, "esc in the search bar must not quit the form")
+	})
+
+	t.Run("the search bar hint follows the bindings", func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "DB_HOST=localhost"})
+		keys := defaultKeyMap()
+		require.NoError(t, keys.rebind(map[string][]string{"filter_scope": {"ctrl+t"}, "filter_clear": {"ctrl+x"}}))
+		m := newModel(options{keys: &keys})
+		m.Init()
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlF})
+		assert.Contains(t, m.View(), "ctrl+t show empty/edited/required • enter apply • ctrl+x clear")
+	})
+
+	t.Run("submitting a filtered form validates the hidden fields", func(t *testing.T) {
+		m := setupModel(t)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlF})
+		typeText(m, "host")
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		require.Equal(t, []int{0}, m.visibleFieldIndices())
+
+		m.form.State = huh.StateCompleted
+		m.Update(nil)
+		require.ErrorIs(t, m.err, errValidation)
+		assert.ErrorContains(t, m.err, "DB_PASSWORD is required")
+		assert.False(t, m.confirming)
+		assert.NoFileExists(t, ".env")
+	})
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitStatus tells how git sees the file the tool writes
+type gitStatus struct {
+	InRepo  bool
+	Root    string // Work tree root
+	Path    string // Path of the file relative to Root, with forward slashes
+	Ignored bool   // Matched by .gitignore or .git/info/exclude
+	Tracked bool   // Listed in the .git index
+}
+
+// checkGitStatus works out whether file is ignored or tracked by git, without running git.
+// Global excludes (core.excludesFile) are not consulted.
+func checkGitStatus(file string) (gitStatus, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return gitStatus{}, err
+	}
+	root, gitDir, err := findGitDir(filepath.Dir(abs))
+	if err != nil || root == "" {
+		return gitStatus{}, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return gitStatus{}, err
+	}
+	status := gitStatus{InRepo: true, Root: root, Path: filepath.ToSlash(rel)}
+
+	if status.Tracked, err = trackedInIndex(filepath.Join(gitDir, "index"), status.Path); err != nil {
+		return status, err
+	}
+	rules, err := loadIgnoreRules(root, gitDir, status.Path)
+	if err != nil {
+		return status, err
+	}
+	status.Ignored = isIgnored(rules, status.Path)
+	return status, nil
+}
+
+// findGitDir walks up from dir to the work tree root. It returns empty strings outside a repository.
+func findGitDir(dir string) (root, gitDir string, err error) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		switch {
+		case err == nil && info.IsDir():
+			return dir, dotGit, nil
+		case err == nil:
+			// Worktrees and submodules have a .git file pointing at the real git directory
+			content, err := os.ReadFile(dotGit)
+			if err != nil {
+				return "", "", err
+			}
+			target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+			if !ok {
+				return "", "", fmt.Errorf("unrecognized %s", dotGit)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			return dir, target, nil
+		case !os.IsNotExist(err):
+			return "", "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+// ignoreRule is one pattern line of an ignore file
+type ignoreRule struct {
+	base     string // Directory of the ignore file relative to the root, "" for the root
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // The pattern contains a slash and matches relative to base only
+}
+
+// loadIgnoreRules reads .git/info/exclude and every .gitignore from the root down to the
+// directory of rel, lowest precedence first
+func loadIgnoreRules(root, gitDir, rel string) ([]ignoreRule, error) {
+	commonDir := gitDir
+	if content, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(content))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	rules, err := parseIgnoreFile(filepath.Join(commonDir, "info", "exclude"), "")
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{""}
+	if dir := path.Dir(rel); dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+		}
+	}
+	for _, dir := range dirs {
+		fileRules, err := parseIgnoreFile(filepath.Join(root, filepath.FromSlash(dir), ".gitignore"), dir)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	return rules, nil
+}
+
+// parseIgnoreFile parses the patterns of one ignore file. A missing file has no patterns.
+func parseIgnoreFile(file, base string) ([]ignoreRule, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// matches reports whether the rule matches rel, a path relative to the root
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if r.anchored {
+		return globMatch(r.pattern, rel)
+	}
+	return globMatch(r.pattern, path.Base(rel))
+}
+
+// isIgnored applies the rules to rel and each of its parent directories. The last matching
+// rule wins, and a file inside an ignored directory cannot be re-included.
+func isIgnored(rules []ignoreRule, rel string) bool {
+	return isIgnoredPath(rules, rel, false)
+}
+
+// isIgnoredPath is isIgnored for a path that may be a directory itself
+func isIgnoredPath(rules []ignoreRule, rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		candidate := strings.Join(parts[:i+1], "/")
+		candidateIsDir := isDir || i < len(parts)-1
+		ignored := false
+		for _, rule := range rules {
+			if rule.matches(candidate, candidateIsDir) {
+				ignored = !rule.negate
+			}
+		}
+		if ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches a slash separated path against a gitignore glob, where ** spans directories
+func globMatch(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(name); skip++ {
+			if matchSegments(pattern[1:], name[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, err := path.Match(strings.ReplaceAll(pattern[0], "[!", "[^"), name[0])
+	return err == nil && ok && matchSegments(pattern[1:], name[1:])
+}
+
+// trackedInIndex reports whether the git index lists rel. Index versions 2 to 4 are supported.
+func trackedInIndex(indexPath, rel string) (bool, error) {
+	data, err := os.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return false, errors.New("unrecognized git index")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	count := binary.BigEndian.Uint32(data[8:12])
+	if version < 2 || version > 4 {
+		return false, fmt.Errorf("unsupported git index version %d", version)
+	}
+	hashSize := 20
+	if objectFormatSHA256(filepath.Dir(indexPath)) {
+		hashSize = 32
+	}
+
+	offset := 12
+	var previous []byte
+	for i := uint32(0); i < count; i++ {
+		start := offset
+		fixed := 40 + hashSize + 2 // Stat data, object name and flags
+		if offset+fixed > len(data) {
+			return false, errors.New("truncated git index")
+		}
+		flags := binary.BigEndian.Uint16(data[offset+40+hashSize:])
+		offset += fixed
+		if version >= 3 && flags&0x4000 != 0 {
+			offset += 2 // Extended flags
+		}
+
+		var name []byte
+		if version == 4 {
+			strip, n := binary.Uvarint(data[offset:])
+			if n <= 0 || int(strip) > len(previous) {
+				return false, errors.New("corrupt git index")
+			}
+			offset += n
+			end := bytes.IndexByte(data[offset:], 0)
+			if end == -1 {
+				return false, errors.New("truncated git index")
+			}
+			name = append(append([]byte{}, previous[:len(previous)-int(strip)]...), data[offset:offset+end]...)
+			offset += end + 1
+		} else {
+			end := bytes.IndexByte(data[offset:], 0)
+			if end == -1 {
+				return false, errors.New("truncated git index")
+			}
+			name = data[offset : offset+end]
+			offset += end + 1
+			// Entries are padded with NULs to a multiple of eight bytes
+			for (offset-start)%8 != 0 {
+				offset++
+			}
+		}
+		if string(name) == rel {
+			return true, nil
+		}
+		previous = name
+	}
+	return false, nil
+}
+
+// objectFormatSHA256 reports whether the repository config selects SHA-256 object names
+func objectFormatSHA256(gitDir string) bool {
+	config, err := os.ReadFile(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(config), "\n") {
+		name, value, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "objectformat") && strings.TrimSpace(value) == "sha256" {
+			return true
+		}
+	}
+	return false
+}
+
+// addIgnoreEntries appends entries to the .gitignore at the work tree root
+func addIgnoreEntries(root string, entries []string) error {
+	file := filepath.Join(root, ".gitignore")
+	existing, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var b strings.Builder
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		b.WriteString("\n")
+	}
+	for _, entry := range entries {
+		b.WriteString(entry + "\n")
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(b.String())
+	return err
+}
+
+// checkGitFiles records how git sees .env and offers ignore entries for it and its companion
+// files when they are neither ignored nor tracked
+func (m *model) checkGitFiles() {
+	envFiles := []string{envOutputFilePath}
+	if len(m.services) > 0 {
+		envFiles = envFiles[:0]
+		for _, s := range m.services {
+			envFiles = append(envFiles, s.EnvFile)
+		}
+	}
+	var err error
+	if m.git, err = checkGitStatus(envFiles[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not check %s against git: %v\n", envFiles[0], err)
+		return
+	}
+	m.ignoreEntries = nil
+	for _, envFile := range envFiles {
+		for _, file := range []string{envFile, envFile + ".old", stampFilePath(envFile), draftFilePath(envFile)} {
+			status, err := checkGitStatus(file)
+			if err == nil && status.InRepo && !status.Ignored && !status.Tracked && status.Root == m.git.Root {
+				m.ignoreEntries = append(m.ignoreEntries, "/"+status.Path)
+			}
+		}
+	}
+}
+
+// trackedSecrets returns the secrets that would be written into a .env tracked by git,
+// unless force allows it
+func trackedSecrets(status gitStatus, envVars []EnvVar, values map[string]string, force bool) []string {
+	if !status.Tracked || force {
+		return nil
+	}
+	var keys []string
+	for _, envVar := range envVars {
+		if isSecret(envVar) && values[envVar.Key] != "" {
+			keys = append(keys, envVar.Key)
+		}
+	}
+	return keys
+}
+
+// gitWarning explains the risk of committing the values about to be written, or returns ""
+func gitWarning(status gitStatus, envVars []EnvVar, values map[string]string, force bool) string {
+	if !status.Tracked {
+		return ""
+	}
+	if keys := trackedSecrets(status, envVars, values, force); len(keys) > 0 {
+		return fmt.Sprintf("⚠ %s is tracked by git and would contain secrets (%s). Saving is refused, untrack it with `git rm --cached %s` or rerun with --force.",
+			status.Path, strings.Join(keys, ", "), status.Path)
+	}
+	return fmt.Sprintf("⚠ %s is tracked by git, these values can end up in a commit.", status.Path)
+}
+
+// saveWarning returns the git warnings for every .env about to be written, or ""
+func (m *model) saveWarning() string {
+	if len(m.services) == 0 {
+		return gitWarning(m.git, m.envVars, m.acceptedValues(), m.opts.force)
+	}
+	var warnings []string
+	for _, s := range m.services {
+		if warning := gitWarning(s.git, s.envVars, m.serviceValues(s), m.opts.force); warning != "" && m.serviceAccepted(s) {
+			warnings = append(warnings, warning)
+		}
+	}
+	return strings.Join(warnings, "\n")
+}
+
+// updateGitignore adds the ignore entries the user accepted to .gitignore
+func (m *model) updateGitignore() {
+	if !m.addIgnore || len(m.ignoreEntries) == 0 {
+		return
+	}
+	if err := addIgnoreEntries(m.git.Root, m.ignoreEntries); err != nil {
+		fmt.Printf("Warning: could not update .gitignore: %v\n", err)
+	} else {
+		fmt.Printf("Added %s to .gitignore.\n", strings.Join(m.ignoreEntries, ", "))
+	}
+}
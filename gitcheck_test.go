@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{".env", ".env", true},
+		{".env*", ".env.local", true},
+		{"*.env", "prod.env", true},
+		{".env", ".env.old", false},
+		{"**/.env", "services/api/.env", true},
+		{"**/.env", ".env", true},
+		{"services/**/.env", "services/a/b/.env", true},
+		{"services/*/.env", "services/a/b/.env", false},
+		{".env.[!e]*", ".env.old", true},
+		{".env.[!e]*", ".env.example", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	root, err := parseIgnoreFile(write(".gitignore", "# secrets\n.env*\n!.env.example\nbuild/\n/config/local.env\n"), "")
+	require.NoError(t, err)
+	sub, err := parseIgnoreFile(write("services/api/.gitignore", "!.env.shared\n"), "services/api")
+	require.NoError(t, err)
+	rules := append(root, sub...)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{".env", true},
+		{".env.old", true},
+		{".env.example", false},
+		{"services/api/.env", true},
+		{"services/api/.env.shared", false},
+		{"services/web/.env.shared", true},
+		{"build/.env.example", true}, // Inside an ignored directory, cannot be re-included
+		{"config/local.env", true},
+		{"services/config/local.env", false},
+	}
+	for _, tt := range tests {
+		if got := isIgnored(rules, tt.path); got != tt.want {
+			t.Errorf("isIgnored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGitStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	setupRepo := func(t *testing.T, indexVersion string) string {
+		tmpDir := t.TempDir()
+		git := func(args ...string) {
+			cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+			cmd.Dir = tmpDir
+			out, err := cmd.CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+		git("init", "-q")
+		createTempFileForModel(t, tmpDir, ".gitignore", ".env.local\n")
+		createTempFileForModel(t, tmpDir, ".env", "TOKEN=committed\n")
+		require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "nested", "deeper"), 0755))
+		createTempFileForModel(t, tmpDir, "nested/deeper/file.txt", "x")
+		git("add", ".gitignore", ".env", "nested/deeper/file.txt")
+		createTempFileForModel(t, tmpDir, "intent.txt", "x")
+		git("add", "--intent-to-add", "intent.txt") // Sets the extended flags of index version 3
+		git("update-index", "--index-version", indexVersion)
+		return tmpDir
+	}
+
+	for _, version := range []string{"2", "3", "4"} {
+		t.Run("index version "+version, func(t *testing.T) {
+			tmpDir := setupRepo(t, version)
+
+			status, err := checkGitStatus(filepath.Join(tmpDir, ".env"))
+			require.NoError(t, err)
+			assert.Equal(t, gitStatus{InRepo: true, Root: tmpDir, Path: ".env", Tracked: true}, status)
+
+			status, err = checkGitStatus(filepath.Join(tmpDir, ".env.local"))
+			require.NoError(t, err)
+			assert.True(t, status.Ignored)
+			assert.False(t, status.Tracked)
+
+			for _, name := range []string{"nested/deeper/file.txt", "intent.txt"} {
+				status, err = checkGitStatus(filepath.Join(tmpDir, name))
+				require.NoError(t, err)
+				assert.True(t, status.Tracked, name)
+			}
+		})
+	}
+
+	t.Run("outside a repository", func(t *testing.T) {
+		status, err := checkGitStatus(filepath.Join(t.TempDir(), ".env"))
+		require.NoError(t, err)
+		assert.False(t, status.InRepo)
+	})
+}
+
+func TestModelGitChecks(t *testing.T) {
+	setupDir := func(t *testing.T, tracked bool) {
+		chdirTemp(t, map[string]string{".env.example": "DB_HOST=localhost\nAPI_TOKEN=", ".gitignore": "node_modules/\n.env.stamp\n"})
+		if !tracked {
+			require.NoError(t, os.Mkdir(".git", 0755))
+			return
+		}
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not installed")
+		}
+		createTempFileForModel(t, ".", ".env", "DB_HOST=localhost\n")
+		for _, args := range [][]string{{"init", "-q"}, {"add", ".env"}} {
+			out, err := exec.Command("git", args...).CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+	}
+
+	t.Run("offers to ignore .env and its companions", func(t *testing.T) {
+		setupDir(t, false)
+
+		m := initialModel()
+		m.Init()
+		require.NoError(t, m.err)
+		assert.Equal(t, []string{"/.env", "/.env.old", "/.env.draft"}, m.ignoreEntries)
+
+		require.NoError(t, m.prepareForConfirmation())
+		m.confirmForm.Init()
+		assert.True(t, m.addIgnore)
+		assert.Contains(t, m.View(), "Not ignored by git yet: /.env /.env.old /.env.draft")
+
+		require.NoError(t, m.actuallyWriteEnvFile(m.acceptedValues()))
+		content, err := os.ReadFile(".gitignore")
+		require.NoError(t, err)
+		assert.Equal(t, "node_modules/\n.env.stamp\n/.env\n/.env.old\n/.env.draft\n", string(content))
+	})
+
+	t.Run("refuses secrets in a tracked file", func(t *testing.T) {
+		setupDir(t, true)
+
+		m := initialModel()
+		m.Init()
+		require.NoError(t, m.err)
+		assert.True(t, m.git.Tracked)
+		assert.Equal(t, []string{"/.env.old", "/.env.draft"}, m.ignoreEntries, "no entry is offered for a tracked .env")
+		m.setFieldValue(1, "t0ken")
+		require.NoError(t, m.prepareForConfirmation())
+		assert.Contains(t, m.View(), "would contain secrets (API_TOKEN)")
+
+		err := m.actuallyWriteEnvFile(m.acceptedValues())
+		assert.ErrorIs(t, err, errTrackedFile)
+		content, _ := os.ReadFile(".env")
+		assert.Equal(t, "DB_HOST=localhost\n", string(content))
+
+		m.opts.force = true
+		assert.NoError(t, m.actuallyWriteEnvFile(m.acceptedValues()))
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// edit is one step of the session history: the value of a field before and after it changed
+type edit struct {
+	Field    int
+	Old, New string
+}
+
+// trackEdits records every field whose value changed since the last call. Typing in a field
+// extends its latest edit until another field gets focus, so undo reverts a whole value at a time.
+func (m *model) trackEdits() {
+	if len(m.snapshot) != len(m.fields) {
+		return
+	}
+	for i := range m.fields {
+		value := m.fieldValue(i)
+		if value == m.snapshot[i] {
+			continue
+		}
+		if n := len(m.undoStack); m.typing == i && n > 0 && m.undoStack[n-1].Field == i {
+			m.undoStack[n-1].New = value
+			if value == m.undoStack[n-1].Old {
+				m.undoStack = m.undoStack[:n-1] // Typed back to where it was
+			}
+		} else {
+			m.undoStack = append(m.undoStack, edit{Field: i, Old: m.snapshot[i], New: value})
+		}
+		m.snapshot[i] = value
+		m.redoStack = nil
+		m.typing = i
+	}
+	if m.typing != m.focusedFieldIndex() {
+		m.typing = -1
+	}
+}
+
+// undo reverts the latest edit, whichever field it was in
+func (m *model) undo() {
+	n := len(m.undoStack)
+	if n == 0 {
+		m.historyStatus = "nothing to undo"
+		return
+	}
+	e := m.undoStack[n-1]
+	m.undoStack = m.undoStack[:n-1]
+	m.redoStack = append(m.redoStack, e)
+	m.snapshot[e.Field] = e.Old
+	m.setFieldValue(e.Field, e.Old)
+	m.historyStatus = "undid " + m.envVars[e.Field].Key
+}
+
+// redo applies the latest undone edit again
+func (m *model) redo() {
+	n := len(m.redoStack)
+	if n == 0 {
+		m.historyStatus = "nothing to redo"
+		return
+	}
+	e := m.redoStack[n-1]
+	m.redoStack = m.redoStack[:n-1]
+	m.undoStack = append(m.undoStack, e)
+	m.snapshot[e.Field] = e.New
+	m.setFieldValue(e.Field, e.New)
+	m.historyStatus = "redid " + m.envVars[e.Field].Key
+}
+
+// updateChangesOverlay handles a key while the "changes so far" overlay is open. Undo and redo
+// work from the overlay, so their effect shows right away.
+func (m *model) updateChangesOverlay(msg tea.KeyMsg) {
+	switch {
+	case key.Matches(msg, m.keys.ShowChanges, m.keys.Close):
+		m.showingChanges = false
+	case m.form != nil && !m.choosingScope && !m.confirming && key.Matches(msg, m.keys.Undo):
+		m.undo()
+	case m.form != nil && !m.choosingScope && !m.confirming && key.Matches(msg, m.keys.Redo):
+		m.redo()
+	}
+}
+
+// changesView renders the changes so far: the diff against .env if the form were saved now,
+// and the edits of this session with the undone ones last
+func (m *model) changesView() string {
+	theme := m.theme()
+	faint := lipgloss.NewStyle().Faint(true)
+	var b strings.Builder
+
+	b.WriteString(theme.Focused.Title.Render("Changes so far") + "\n")
+	values, err := m.collectValues()
+	if err != nil {
+		return fmt.Sprintf("\nError: %v\n", err)
+	}
+	changes := m.changeSet(values)
+	if len(changes) == 0 {
+		b.WriteString(faint.Render(noChangesMessage()) + "\n")
+	}
+	for _, c := range changes {
+		b.WriteString(m.palette().Changes[c.Kind].Render(c.String()) + "\n")
+	}
+
+	b.WriteString("\n" + theme.Focused.Title.Render("Edits this session") + "\n")
+	if len(m.undoStack) == 0 && len(m.redoStack) == 0 {
+		b.WriteString(faint.Render("None yet") + "\n")
+	}
+	for i, e := range m.undoStack {
+		fmt.Fprintf(&b, "%d. %s: %q -> %q\n", i+1, m.envVars[e.Field].Key, e.Old, e.New)
+	}
+	for i := len(m.redoStack) - 1; i >= 0; i-- {
+		e := m.redoStack[i]
+		b.WriteString(faint.Strikethrough(true).Render(fmt.Sprintf("%s: %q -> %q", m.envVars[e.Field].Key, e.Old, e.New)) + faint.Render(" (undone)") + "\n")
+	}
+
+	help := fmt.Sprintf("%s close", m.keys.ShowChanges.Help().Key)
+	if m.form != nil && !m.choosingScope && !m.confirming {
+		help = fmt.Sprintf("%s • %s • %s", helpText(m.keys.Undo), helpText(m.keys.Redo), help)
+	}
+	b.WriteString("\n" + faint.Render(help))
+
+	style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(m.palette().Border).Padding(0, 1)
+	if m.width > 0 {
+		style = style.Width(m.width - 2)
+	}
+	if m.height > 0 {
+		style = style.MaxHeight(m.height)
+	}
+	return style.Render(b.String())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUndoRedo(t *testing.T) {
+	setupDir := func(t *testing.T) *model {
+		chdirTemp(t, map[string]string{".env.example": "APP_NAME=app\nAPP_PORT=8080\n"})
+		m := initialModel()
+		m.Init()
+		return m
+	}
+	typeText := func(m *model, text string) {
+		for _, r := range text {
+			m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+
+	t.Run("across fields", func(t *testing.T) {
+		m := setupDir(t)
+		typeText(m, "-api")
+		m.form.NextField()
+		typeText(m, "1")
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlR}) // Back to the example default
+		assert.Equal(t, []edit{{0, "app", "app-api"}, {1, "8080", "80801"}, {1, "80801", "8080"}}, m.undoStack,
+			"typing in one field is one edit")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+		assert.Equal(t, "80801", m.fieldValue(1))
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+		assert.Equal(t, "app", m.fieldValue(0), "undo reaches fields that lost focus")
+		assert.Equal(t, "8080", m.fieldValue(1))
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+		assert.Equal(t, "nothing to undo", m.historyStatus)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+		assert.Equal(t, "app-api", m.fieldValue(0))
+		assert.Equal(t, "redid APP_NAME", m.historyStatus)
+		assert.Len(t, m.redoStack, 2)
+
+		typeText(m, "9")
+		assert.Empty(t, m.redoStack, "a new edit drops the undone ones")
+		assert.Equal(t, "80809", m.fieldValue(1))
+		assert.Equal(t, edit{1, "8080", "80809"}, m.undoStack[len(m.undoStack)-1])
+	})
+
+	t.Run("typing a value back is no edit", func(t *testing.T) {
+		m := setupDir(t)
+		typeText(m, "x")
+		m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+		assert.Empty(t, m.undoStack)
+	})
+
+	t.Run("changes so far", func(t *testing.T) {
+		m := setupDir(t)
+		typeText(m, "-api")
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+		require.True(t, m.showingChanges)
+		view := m.View()
+		assert.Contains(t, view, "Changes so far")
+		assert.Contains(t, view, `+ Added: APP_NAME="app-api"`)
+		assert.Contains(t, view, `1. APP_NAME: "app" -> "app-api"`)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+		view = m.View()
+		assert.Contains(t, view, `+ Added: APP_NAME="app"`)
+		assert.Contains(t, view, "(undone)")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.False(t, m.showingChanges)
+		assert.Equal(t, huh.StateNormal, m.form.State, "esc closes the overlay, not the form")
+		assert.False(t, m.quitting)
+	})
+}
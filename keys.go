@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/huh"
+)
+
+// keyMap holds the bindings handled by the model itself, on top of the huh form key map
+type keyMap struct {
+	Quit     key.Binding // Leave the main form without saving
+	Close    key.Binding // Close a sub-form or overlay without changes
+	Next     key.Binding // Move to the next field of the main form
+	Prev     key.Binding // Move to the previous field of the main form
+	ShowHelp key.Binding // Open or close the key binding overlay
+
+	Filter      key.Binding // Open the search bar in the main form
+	FilterScope key.Binding // Cycle between all, empty, edited and required fields
+	FilterApply key.Binding // Close the search bar and keep the filter
+	FilterClear key.Binding // Close the search bar and show every field again
+
+	ResetDefault    key.Binding // Set the focused field to its example default
+	RestoreOriginal key.Binding // Set the focused field back to its value in the existing .env
+	EditParts       key.Binding // Open the parts form of the focused url or dsn field
+	Browse          key.Binding // Open the file picker of the focused path, file or dir field
+
+	Undo        key.Binding // Revert the latest edit of any field
+	Redo        key.Binding // Apply the latest undone edit again
+	ShowChanges key.Binding // Open or close the "changes so far" overlay
+
+	ReviewUp     key.Binding // Move the cursor in the change list
+	ReviewDown   key.Binding
+	ReviewToggle key.Binding // Accept or reject the change under the cursor
+	ReviewEdit   key.Binding // Go back to the form to edit the field under the cursor
+}
+
+func defaultKeyMap() keyMap {
+	return keyMap{
+		Quit:     key.NewBinding(key.WithKeys("esc", "ctrl+c"), key.WithHelp("esc/ctrl+c", "quit")),
+		Close:    key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "close")),
+		Next:     key.NewBinding(key.WithKeys("enter", "tab", "down"), key.WithHelp("enter/tab/↓", "next")),
+		Prev:     key.NewBinding(key.WithKeys("shift+tab", "up"), key.WithHelp("shift+tab/↑", "prev")),
+		ShowHelp: key.NewBinding(key.WithKeys("ctrl+g", "f1"), key.WithHelp("ctrl+g", "key bindings")),
+
+		Filter:      key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "search")),
+		FilterScope: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "show empty/edited/required")),
+		FilterApply: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "apply")),
+		FilterClear: key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "clear")),
+
+		ResetDefault:    key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "example default")),
+		RestoreOriginal: key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", ".env value")),
+		EditParts:       key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "edit parts")),
+		Browse:          key.NewBinding(key.WithKeys("ctrl+p"), key.WithHelp("ctrl+p", "browse")),
+
+		Undo:        key.NewBinding(key.WithKeys("ctrl+z"), key.WithHelp("ctrl+z", "undo")),
+		Redo:        key.NewBinding(key.WithKeys("ctrl+y"), key.WithHelp("ctrl+y", "redo")),
+		ShowChanges: key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "changes so far")),
+
+		ReviewUp:     key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+		ReviewDown:   key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↑/↓", "move")),
+		ReviewToggle: key.NewBinding(key.WithKeys(" ", "x"), key.WithHelp("space", "accept/reject")),
+		ReviewEdit:   key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit field")),
+	}
+}
+
+// actions maps the action names used in .setup-env.yaml to the bindings
+func (k *keyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":             &k.Quit,
+		"close":            &k.Close,
+		"next":             &k.Next,
+		"prev":             &k.Prev,
+		"show_help":        &k.ShowHelp,
+		"filter":           &k.Filter,
+		"filter_scope":     &k.FilterScope,
+		"filter_apply":     &k.FilterApply,
+		"filter_clear":     &k.FilterClear,
+		"reset_default":    &k.ResetDefault,
+		"restore_original": &k.RestoreOriginal,
+		"edit_parts":       &k.EditParts,
+		"browse":           &k.Browse,
+		"undo":             &k.Undo,
+		"redo":             &k.Redo,
+		"show_changes":     &k.ShowChanges,
+		"review_up":        &k.ReviewUp,
+		"review_down":      &k.ReviewDown,
+		"review_toggle":    &k.ReviewToggle,
+		"review_edit":      &k.ReviewEdit,
+	}
+}
+
+// keyPresets are alternative starting points for the bindings, chosen with the key_preset
+// setting. They list the actions they change; the keys setting is applied on top.
+var keyPresets = map[string]map[string][]string{
+	defaultKeyPreset: nil,
+	// vim keeps esc from quitting, since it is pressed out of habit, and moves between
+	// fields with alt+j and alt+k. Letters alone cannot be used, they are typed into fields.
+	"vim": {
+		"quit": {"ctrl+q", "ctrl+c"},
+		"next": {"enter", "tab", "down", "alt+j"},
+		"prev": {"shift+tab", "up", "alt+k"},
+	},
+}
+
+const defaultKeyPreset = "default"
+
+// keyPresetNames lists the known preset names in order
+func keyPresetNames() []string {
+	names := make([]string, 0, len(keyPresets))
+	for name := range keyPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rebind replaces the keys of the named actions, keeping their help descriptions
+func (k *keyMap) rebind(bindings map[string][]string) error {
+	actions := k.actions()
+	for name, keys := range bindings {
+		binding, ok := actions[name]
+		if !ok {
+			names := make([]string, 0, len(actions))
+			for name := range actions {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown key action %q, expected one of %s", name, strings.Join(names, ", "))
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no keys given for action %q", name)
+		}
+		*binding = key.NewBinding(key.WithKeys(keys...), key.WithHelp(strings.Join(keys, "/"), binding.Help().Desc))
+	}
+	return nil
+}
+
+// keysByAction returns the keys of every action
+func (k *keyMap) keysByAction() map[string][]string {
+	keys := make(map[string][]string)
+	for name, binding := range k.actions() {
+		keys[name] = binding.Keys()
+	}
+	return keys
+}
+
+// formKeyMap returns the huh key map of the main form and the other full-screen forms with
+// the model's quit and navigation bindings
+func (k *keyMap) formKeyMap() *huh.KeyMap {
+	formKeys := huh.NewDefaultKeyMap()
+	formKeys.Quit = k.Quit
+	formKeys.Input.Next = k.Next
+	formKeys.Input.Prev = k.Prev
+	return formKeys
+}
+
+// subFormKeyMap returns the huh key map of the forms opened from the main form, which close
+// instead of quitting
+func (k *keyMap) subFormKeyMap() *huh.KeyMap {
+	formKeys := k.formKeyMap()
+	formKeys.Quit = k.Close
+	return formKeys
+}
+
+// ShortHelp lists the most used bindings, for the help.KeyMap interface
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Next, k.Prev, k.Filter, k.Undo, k.ShowHelp, k.Quit}
+}
+
+// FullHelp lists the bindings in columns: moving around, editing, views and the review
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Next, k.Prev, k.Quit, k.Close, k.ShowHelp},
+		{k.ResetDefault, k.RestoreOriginal, k.EditParts, k.Browse, k.Undo, k.Redo},
+		{k.Filter, k.FilterScope, k.FilterApply, k.FilterClear, k.ShowChanges},
+		{k.ReviewDown, k.ReviewToggle, k.ReviewEdit},
+	}
+}
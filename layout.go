@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// layoutMode picks how much chrome the UI shows for the current terminal width
+type layoutMode int
+
+const (
+	layoutCompact layoutMode = iota // Narrow terminals: no help footer or hints
+	layoutRegular
+	layoutWide // Wide terminals: a side panel shows the focused variable's full description
+)
+
+const (
+	compactMaxWidth = 60
+	wideMinWidth    = 120
+	sidePanelRatio  = 0.4 // Share of a wide terminal given to the side panel
+	defaultWidth    = 80  // Used until the first tea.WindowSizeMsg arrives
+)
+
+func layoutFor(width int) layoutMode {
+	switch {
+	case width > 0 && width < compactMaxWidth:
+		return layoutCompact
+	case width >= wideMinWidth:
+		return layoutWide
+	default:
+		return layoutRegular
+	}
+}
+
+// formWidth returns the width available to the forms for the current terminal size
+func (m *model) formWidth() int {
+	switch layoutFor(m.width) {
+	case layoutCompact:
+		return m.width
+	case layoutWide:
+		return m.width - m.sidePanelWidth()
+	}
+	if m.width == 0 {
+		return defaultWidth
+	}
+	return m.width
+}
+
+func (m *model) sidePanelWidth() int {
+	return int(float64(m.width) * sidePanelRatio)
+}
+
+// formHeight returns the height available to a form, leaving room for the lines rendered around it
+func (m *model) formHeight(reserved int) int {
+	if m.height == 0 {
+		return 0
+	}
+	return max(m.height-reserved, 3)
+}
+
+// applyLayout sizes every form to the terminal. Groups taller than the terminal scroll.
+func (m *model) applyLayout() {
+	width := m.formWidth()
+	showHelp := layoutFor(m.width) != layoutCompact
+	if m.form != nil {
+		m.form.WithWidth(width).WithShowHelp(showHelp)
+		if h := m.formHeight(m.mainFormChromeHeight()); h > 0 {
+			m.form.WithHeight(h)
+		}
+	}
+	if m.serviceForm != nil {
+		m.serviceForm.WithWidth(width).WithShowHelp(showHelp)
+	}
+	if m.draftForm != nil {
+		m.draftForm.WithWidth(width).WithShowHelp(showHelp)
+	}
+	if m.scopeForm != nil {
+		m.scopeForm.WithWidth(width).WithShowHelp(showHelp)
+	}
+	if m.confirmForm != nil {
+		m.confirmForm.WithWidth(width).WithShowHelp(showHelp)
+	}
+	if m.quitForm != nil {
+		m.quitForm.WithWidth(width).WithShowHelp(showHelp)
+	}
+	if m.partsForm != nil {
+		m.partsForm.WithWidth(width).WithShowHelp(showHelp)
+	}
+	if m.pickerForm != nil {
+		m.pickerForm.WithWidth(width).WithShowHelp(showHelp)
+		if h := m.formHeight(0); h > 0 {
+			m.pickerForm.WithHeight(h)
+		}
+	}
+}
+
+// mainFormChromeHeight is the number of lines the main view renders besides the form
+func (m *model) mainFormChromeHeight() int {
+	if layoutFor(m.width) == layoutCompact && !m.filtering {
+		return 0
+	}
+	return lipgloss.Height(m.filterView()) + 1
+}
+
+// sidePanelView renders the full description of the focused variable for wide terminals
+func (m *model) sidePanelView() string {
+	i := m.focusedFieldIndex()
+	if i == -1 {
+		return ""
+	}
+	envVar := m.envVars[i]
+
+	theme := m.theme()
+	body := theme.Focused.Title.Render(envVar.Key) + "\n\n"
+	if envVar.Description != "" {
+		body += envVar.Description + "\n\n"
+	}
+	if envVar.ExampleValue != "" {
+		body += theme.Focused.Description.Render(fmt.Sprintf("Example: %s", envVar.ExampleValue)) + "\n"
+	}
+	if original, ok := m.existingEnvValues[envVar.Key]; ok {
+		body += theme.Focused.Description.Render(fmt.Sprintf("%s value: %s", envOutputFilePath, original)) + "\n"
+	}
+	body += theme.Focused.Description.Render(fmt.Sprintf("Source: %s", m.currentSource(i))) + "\n"
+	if hasParts(envVar) {
+		body += theme.Focused.Description.Render(fmt.Sprintf("Type: %s, %s to edit its parts", envVar.Type, m.keys.EditParts.Help().Key)) + "\n"
+	}
+	if isPathType(envVar) {
+		body += theme.Focused.Description.Render(fmt.Sprintf("Type: %s, %s to browse", envVar.Type, m.keys.Browse.Help().Key)) + "\n"
+		if value := m.fieldValue(i); value != "" {
+			path := resolvePath(value, m.projectDir(envVar.Key))
+			if m.pathMissing(i) {
+				path += " (missing)"
+			}
+			body += theme.Focused.Description.Render("Path: "+path) + "\n"
+		}
+	}
+	if result, ok := m.checks[envVar.Key]; ok {
+		body += theme.Focused.Description.Render("Check: "+result.String()) + "\n"
+	}
+	if services := m.usedBy[envVar.Key]; len(services) > 0 {
+		body += theme.Focused.Description.Render(fmt.Sprintf("Used by: %s", strings.Join(services, ", "))) + "\n"
+	}
+	if envVar.Required {
+		body += theme.Focused.Description.Render("Required") + "\n"
+	}
+
+	return lipgloss.NewStyle().
+		Width(m.sidePanelWidth()-4).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.palette().Border).
+		Padding(0, 1).
+		Render(body)
+}
+
+// mainView renders the main form with the search bar and, on wide terminals, the side panel
+func (m *model) mainView() string {
+	view := m.form.View()
+	switch layoutFor(m.width) {
+	case layoutCompact:
+		if m.filtering {
+			view += "\n" + m.filterView()
+		}
+		return view
+	case layoutWide:
+		view = lipgloss.JoinHorizontal(lipgloss.Top, view, m.sidePanelView())
+	}
+	return view + "\n" + m.filterView()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutFor(t *testing.T) {
+	assert.Equal(t, layoutRegular, layoutFor(0), "Unknown size should use the regular layout")
+	assert.Equal(t, layoutCompact, layoutFor(40))
+	assert.Equal(t, layoutRegular, layoutFor(80))
+	assert.Equal(t, layoutWide, layoutFor(160))
+}
+
+func TestModelLayout(t *testing.T) {
+	longDescription := "Connection string for the reporting database, see https://example.com/docs/reporting/database/connection-strings for details"
+	setupModel := func(t *testing.T) *model {
+		chdirTemp(t, map[string]string{".env.example": "REPORTING_DB=postgres://localhost/reports # " + longDescription + "\nAPP_NAME=app"})
+
+		m := initialModel()
+		_ = m.Init()
+		require.Nil(t, m.err)
+		return m
+	}
+	maxLineWidth := func(view string) int {
+		widest := 0
+		for _, line := range strings.Split(view, "\n") {
+			widest = max(widest, lipgloss.Width(line))
+		}
+		return widest
+	}
+
+	t.Run("wide terminal shows the side panel", func(t *testing.T) {
+		m := setupModel(t)
+
+		m.Update(tea.WindowSizeMsg{Width: 160, Height: 40})
+		view := m.View()
+		assert.Contains(t, view, "Example: postgres://localhost/reports")
+		assert.Contains(t, view, "ctrl+f")
+		assert.LessOrEqual(t, maxLineWidth(view), 160)
+	})
+
+	t.Run("regular terminal uses its full width", func(t *testing.T) {
+		m := setupModel(t)
+
+		m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+		view := m.View()
+		assert.NotContains(t, view, "Example: postgres://localhost/reports")
+		assert.LessOrEqual(t, maxLineWidth(view), 100)
+	})
+
+	t.Run("narrow terminal uses the compact mode", func(t *testing.T) {
+		m := setupModel(t)
+
+		m.Update(tea.WindowSizeMsg{Width: 50, Height: 20})
+		view := m.View()
+		assert.NotContains(t, view, "ctrl+f", "Hints are hidden in compact mode")
+		assert.LessOrEqual(t, maxLineWidth(view), 50)
+		assert.LessOrEqual(t, lipgloss.Height(view), 20)
+	})
+
+	t.Run("confirmation follows the terminal width", func(t *testing.T) {
+		m := setupModel(t)
+
+		m.Update(tea.WindowSizeMsg{Width: 70, Height: 30})
+		longValue := "postgres://localhost/" + strings.Repeat("x", 120)
+		m.fields[0].(*huh.Input).Value(&longValue)
+		require.NoError(t, m.prepareForConfirmation())
+		require.True(t, m.confirming)
+		assert.LessOrEqual(t, maxLineWidth(m.View()), 70)
+	})
+}
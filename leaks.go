@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// tokenPattern recognizes a credential format by its well-known prefix
+type tokenPattern struct {
+	Name             string
+	Regexp           *regexp.Regexp
+	AllowedInSecrets bool // Not flagged in secret variables, for formats that are expected there
+}
+
+// leakRules configures the detectors that look for credentials pasted into the form
+type leakRules struct {
+	Tokens           []tokenPattern
+	EntropyThreshold float64  // Bits per character above which a word in a non-secret value looks random
+	EntropyMinLength int      // Shorter words are never flagged for entropy
+	ProductionHosts  []string // Glob patterns of hostnames that must not appear in a local .env
+}
+
+// defaultProductionHosts is the production denylist unless --production-hosts replaces it
+var defaultProductionHosts = []string{"prod.*", "*.prod.*", "prod-*", "*-prod.*", "production.*", "*.production.*"}
+
+// defaultTokenPatterns are the credential formats flagged unless the token_patterns setting
+// replaces them, keyed by the name shown in the warning
+var defaultTokenPatterns = map[string]string{
+	"an AWS access key": `\b(AKIA|ASIA)[0-9A-Z]{16}\b`,
+	"a GitHub token":    `\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`,
+	"a Slack token":     `\bxox[abeprs]-[A-Za-z0-9-]{10,}`,
+	"a Stripe live key": `\b[rs]k_live_[A-Za-z0-9]{16,}`,
+}
+
+// defaultEntropyThreshold is the entropy_threshold setting unless a config file changes it
+const defaultEntropyThreshold = 4.0
+
+// newLeakRules compiles the token patterns, in order of their names, into a rule set. The
+// patterns named in allowedInSecrets are not flagged in secret variables.
+func newLeakRules(tokenPatterns map[string]string, allowedInSecrets []string, entropyThreshold float64, productionHosts []string) (*leakRules, error) {
+	for _, name := range allowedInSecrets {
+		if _, ok := tokenPatterns[name]; !ok {
+			return nil, fmt.Errorf("unknown token pattern %q in secret_tokens_allowed", name)
+		}
+	}
+	names := make([]string, 0, len(tokenPatterns))
+	for name := range tokenPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	r := &leakRules{EntropyThreshold: entropyThreshold, EntropyMinLength: 20, ProductionHosts: productionHosts}
+	for _, name := range names {
+		re, err := regexp.Compile(tokenPatterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token pattern %q: %w", name, err)
+		}
+		r.Tokens = append(r.Tokens, tokenPattern{Name: name, Regexp: re, AllowedInSecrets: slices.Contains(allowedInSecrets, name)})
+	}
+	return r, nil
+}
+
+func defaultLeakRules() *leakRules {
+	r, err := newLeakRules(defaultTokenPatterns, nil, defaultEntropyThreshold, defaultProductionHosts)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+var (
+	hostnamePattern = regexp.MustCompile(`[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+`)
+	wordSeparators  = regexp.MustCompile(`[^A-Za-z0-9+/=_-]+`)
+)
+
+// check returns a warning for each detector that flags the value of envVar. Secrets are
+// where random strings belong, so they are not checked for randomness, but a production token
+// pasted into one is still flagged unless its pattern is allowed in secrets. Paths are not
+// checked for randomness either, generated directory names look random too.
+// Empty rules, as --no-leak-checks passes, flag nothing.
+func (r *leakRules) check(envVar EnvVar, value string) []string {
+	if value == "" {
+		return nil
+	}
+	var warnings []string
+	for _, token := range r.Tokens {
+		if (!token.AllowedInSecrets || !isSecret(envVar)) && token.Regexp.MatchString(value) {
+			warnings = append(warnings, "looks like "+token.Name)
+		}
+	}
+	if !isSecret(envVar) && !isPathType(envVar) && r.EntropyThreshold > 0 {
+		for _, word := range wordSeparators.Split(value, -1) {
+			if len(word) >= r.EntropyMinLength && shannonEntropy(word) >= r.EntropyThreshold {
+				warnings = append(warnings, "looks like a random secret in a field not marked @secret")
+				break
+			}
+		}
+	}
+	if host := r.productionHost(value); host != "" {
+		warnings = append(warnings, "points at production host "+host)
+	}
+	return warnings
+}
+
+// productionHost returns the first hostname in value that matches the production denylist
+func (r *leakRules) productionHost(value string) string {
+	candidates := hostnamePattern.FindAllString(value, -1)
+	if u, err := url.Parse(value); err == nil && u.Hostname() != "" {
+		candidates = append([]string{u.Hostname()}, candidates...)
+	}
+	for _, host := range candidates {
+		host = strings.ToLower(host)
+		for _, pattern := range r.ProductionHosts {
+			if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+				return host
+			}
+		}
+	}
+	return ""
+}
+
+// shannonEntropy returns the average information per character of s in bits
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// hasLeakWarnings reports whether an accepted change was flagged by the detectors
+func (m *model) hasLeakWarnings() bool {
+	for i, c := range m.changes {
+		if m.accepted[i] && len(c.Warnings) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,38 +1,256 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 func main() {
-	m := initialModel()
+	if len(os.Args) > 1 {
+		var run func([]string) int
+		switch os.Args[1] {
+		case "exec":
+			run = runExec
+		case "encrypt":
+			run = runEncrypt
+		case "decrypt":
+			run = runDecrypt
+		}
+		if run != nil {
+			s, _, err := loadSettings(".", settings{})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+				os.Exit(exitUsage)
+			}
+			applySettings(s)
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	args := os.Args[1:]
+	showConfig := len(args) > 0 && args[0] == "config"
+	if showConfig {
+		args = args[1:]
+	}
+
+	flags := flag.NewFlagSet("setup-env", flag.ExitOnError)
+	onlyNew := flags.Bool("only-new", false, "only prompt for variables that are new or changed since the last run")
+	quietIfCurrent := flags.Bool("quiet-if-current", false, "exit silently with status 0 if .env is up to date with .env.example, 8 otherwise")
+	fromEnv := flags.Bool("from-env", false, "prefill fields from the current process environment (same as --prefill-order dotenv,env,example)")
+	prefillOrderFlag := flags.String("prefill-order", "", "comma separated prefill sources in priority order: dotenv, enc, env, example")
+	answersFile := flags.String("answers", "", "YAML or JSON file with values for some keys; those keys are not prompted")
+	plain := flags.Bool("plain", false, "ask one question per line instead of the full-screen form (default when not run in a terminal)")
+	accessible := flags.Bool("accessible", false, "like --plain, but let huh's accessible mode ask the questions")
+	dryRun := flags.Bool("dry-run", false, "print the changes the prefilled values would make, with secrets redacted, and write nothing")
+	output := flags.String("output", "text", "format of the --dry-run report: text or json")
+	template := flags.String("template", "", "template to read instead of .env.example, a file or an http(s) URL with an optional #sha256= pin")
+	envFile := flags.String("env-file", "", "file to write instead of .env")
+	theme := flags.String("theme", "", "color theme: "+strings.Join(themeNames(), ", "))
+	keyPreset := flags.String("key-preset", "", "key bindings to start from: "+strings.Join(keyPresetNames(), ", "))
+	backup := flags.String("backup", "", "backup policy for the existing .env: single, timestamped or none")
+	productionHosts := flags.String("production-hosts", "", "comma separated hostname patterns that count as production")
+	noLeakChecks := flags.Bool("no-leak-checks", false, "do not warn about values that look like leaked or production credentials")
+	offline := flags.Bool("offline", false, "skip the @check reachability checks")
+	force := flags.Bool("force", false, "write secrets into .env even when git tracks it")
+	keyFile := flags.String("key-file", "", "file holding the passphrase for "+encryptedFilePath+", instead of $"+passphraseEnvVar+" or a prompt")
+	recursive := flags.Bool("recursive", false, "find every .env.example below the current directory, skipping what git ignores, and set them up together")
+	recordFile := flags.String("record", "", "write the values chosen in the form, minus secrets, to this YAML or JSON answers file")
+	flags.Parse(args)
+
+	overrides := settings{Template: *template, EnvFile: *envFile, Theme: *theme, Backup: *backup, KeyPreset: *keyPreset}
+	if *productionHosts != "" {
+		overrides.ProductionHosts = splitList(*productionHosts)
+	}
+	s, configPath, err := loadSettings(".", overrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	keys, err := s.keyMap()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if showConfig {
+		if err := printSettings(os.Stdout, s, configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+			os.Exit(exitError)
+		}
+		return
+	}
+	applySettings(s)
+	noColor := colorDisabled(os.Getenv)
+	if noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	var prefillOrder []valueSource
+	switch {
+	case *prefillOrderFlag != "":
+		var err error
+		if prefillOrder, err = parsePrefillOrder(*prefillOrderFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	case *fromEnv:
+		prefillOrder = []valueSource{sourceDotEnv, sourceEnvironment, sourceExample}
+	}
+	if *prefillOrderFlag == "" && *keyFile != "" {
+		prefillOrder = withEncrypted(prefillOrder)
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "setup-env: unknown output format %q, expected text or json\n", *output)
+		os.Exit(exitUsage)
+	}
+
+	var services []service
+	if *recursive {
+		if isTemplateURL(templateFilePath) {
+			fmt.Fprintln(os.Stderr, "setup-env: --recursive looks for template files by name and cannot use a template URL")
+			os.Exit(exitUsage)
+		}
+		var err error
+		if services, err = discoverServices(".", filepath.Base(templateFilePath), filepath.Base(envOutputFilePath)); err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+			os.Exit(exitError)
+		}
+		if len(services) == 0 {
+			fmt.Fprintf(os.Stderr, "setup-env: no %s found below the current directory\n", filepath.Base(templateFilePath))
+			os.Exit(exitTemplateMissing)
+		}
+	}
+
+	if *quietIfCurrent {
+		if *recursive {
+			os.Exit(checkTemplatesCurrent(services))
+		}
+		os.Exit(checkTemplateCurrent(templateFilePath, envOutputFilePath))
+	}
+
+	var answers map[string]string
+	if *answersFile != "" {
+		var err error
+		if answers, err = readAnswersFile(*answersFile); err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	var encrypted map[string]string
+	if containsSource(prefillOrder, sourceEncrypted) {
+		var err error
+		if encrypted, err = loadEncryptedSource(encryptedFilePath, *keyFile, isInteractive() && !*dryRun); err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	leaks, err := s.leakRules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if *noLeakChecks {
+		leaks = &leakRules{}
+	}
+
+	m := newModel(options{
+		onlyNew:      *onlyNew,
+		prefillOrder: prefillOrder,
+		answers:      answers,
+		recordFile:   *recordFile,
+		encrypted:    encrypted,
+		keyFile:      *keyFile,
+		services:     services,
+		force:        *force,
+		offline:      *offline,
+		leaks:        leaks,
+		theme:        s.Theme,
+		noColor:      noColor,
+		confirmQuit:  *s.ConfirmQuit,
+		backup:       s.Backup,
+		keys:         &keys,
+	})
+	if *dryRun {
+		err := runDryRun(m, os.Stdout, *output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		}
+		os.Exit(exitCode(err))
+	}
+	if *plain || *accessible || !isInteractive() || isDumbTerminal(os.Getenv) {
+		err := runPlain(m, os.Stdin, os.Stdout, *accessible)
+		if err != nil && !errors.Is(err, errAborted) {
+			fmt.Printf("%v\n", err)
+		}
+		os.Exit(exitCode(err))
+	}
+
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
 	finalModel, err := p.Run()
 	if err != nil {
 		fmt.Printf("Error running program: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
-	if fm, ok := finalModel.(*model); ok {
-		if fm.err != nil {
-			// If no changes were made, diffSummary will contain "No changes..."
-			// and fm.err will be nil if prepareForConfirmation quit early.
-			// Only print fm.err if it's a real error.
-			if fm.diffSummary != "No changes to apply to .env file." || fm.err.Error() != "" { // Check if it's not the "no changes" message
-				fmt.Printf("%v\n", fm.err)
-			}
-			if strings.Contains(fm.err.Error(), ".env.example file not found") || strings.Contains(fm.err.Error(), "No environment variables found") {
-				os.Exit(1)
-			}
-		} else if fm.diffSummary == "No changes to apply to .env file." && !fm.confirming {
-			// If quitting because no changes, print the summary.
-			// This ensures "No changes to apply..." is visible if that was the reason for quitting.
-			fmt.Println("\n" + fm.diffSummary)
+	fm, ok := finalModel.(*model)
+	if !ok {
+		os.Exit(exitError)
+	}
+	if fm.err != nil {
+		fmt.Printf("%v\n", fm.err)
+	} else if fm.diffSummary == noChangesMessage() && !fm.confirming {
+		// If quitting because no changes, print the summary.
+		// This ensures "No changes to apply..." is visible if that was the reason for quitting.
+		fmt.Println("\n" + fm.diffSummary)
+	}
+	os.Exit(exitCode(fm.exitErr()))
+}
+
+// applySettings points the file paths and secret detection at the configured values
+func applySettings(s settings) {
+	templateFilePath = s.Template
+	templateExtends = s.Extends
+	envOutputFilePath = s.EnvFile
+	secretKeyPatterns = s.SecretPatterns
+}
+
+// checkTemplateCurrent is the cheap check behind --quiet-if-current, meant for shell prompts and git hooks.
+// It prints nothing when the .env is current and returns the process exit status.
+func checkTemplateCurrent(templatePath, envPath string) int {
+	keys, err := templateStatus(templatePath, envPath)
+	if errors.Is(err, errTemplateNotCached) {
+		fmt.Fprintf(os.Stderr, "setup-env: %v, run setup-env to fetch it\n", err)
+		return exitOutOfDate
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "setup-env: %v\n", err)
+		return exitError
+	}
+	if len(keys) == 0 {
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "setup-env: %d new or changed variables in %s: %s\n", len(keys), templatePath, strings.Join(keys, ", "))
+	return exitOutOfDate
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
+	return items
 }
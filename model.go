@@ -1,13 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
 )
 
 // EnvVar holds a key and its description from the .env.example file
@@ -15,84 +21,361 @@ type EnvVar struct {
 	Key          string
 	Description  string
 	ExampleValue string // Value from .env.example
+	Required     bool   // Marked with @required in the description
+	Secret       bool   // Marked with @secret in the description
+	Check        string // Reachability check from @check=tcp|http|dns, if any
+	Type         string // Value type from @type=url|dsn|path|file|dir, if any
+}
+
+// options holds the command-line settings that change how the model behaves
+type options struct {
+	onlyNew      bool              // Only prompt for variables that are new or changed since the last run
+	prefillOrder []valueSource     // Sources considered when prefilling fields, highest priority first
+	answers      map[string]string // Pre-resolved values from --answers, these fields are not prompted
+	recordFile   string            // Where to record the answers given in the form, if set
+	encrypted    map[string]string // Decrypted values of .env.enc, a prefill source
+	keyFile      string            // Passphrase file from --key-file, also used for the secrets of drafts
+	services     []service         // Templates found by --recursive, set up together
+	theme        string            // Name of the huh theme, charm when empty
+	noColor      bool              // Colors are off, for NO_COLOR or a dumb terminal
+	confirmQuit  bool              // Ask before quitting the main form with unsaved edits
+	backup       string            // Backup policy for the existing .env, single when empty
+	keys         *keyMap           // Key bindings, the defaults when nil
+	leaks        *leakRules        // Leaked credential detectors, the defaults when nil
+	force        bool              // Write secrets even into a .env tracked by git
+	offline      bool              // Skip the @check reachability checks
+	dryRun       bool              // Only report the changes, never write .env, the stamp or answers
+}
+
+// noChangesMessage is shown when the form values match the existing .env
+func noChangesMessage() string {
+	return fmt.Sprintf("No changes to apply to %s file.", envOutputFilePath)
 }
 
 type model struct {
+	opts              options
 	form              *huh.Form
 	envVars           []EnvVar
 	existingEnvValues map[string]string
+	processEnv        map[string]string // Only loaded when the environment is a prefill source
+	envFileExists     bool
 	fields            []huh.Field
+	initialValues     []string      // Prefilled value of each field, parallel to fields
+	sources           []valueSource // Where each prefilled value came from, parallel to fields
+	titles            []string      // Current field titles including the source badge
+	keys              keyMap
 	width, height     int
 	quitting          bool
+	aborted           bool // The user cancelled or discarded the changes
 	err               error
 
+	// Fields for the service picker shown first with --recursive
+	choosingServices bool
+	serviceForm      *huh.Form
+	selectedServices []string
+	services         []service           // The services being set up, loaded after the picker
+	usedBy           map[string][]string // Services using each key
+
+	// Fields for resuming the answers of a run that was cancelled before saving
+	draftForm    *huh.Form
+	resumeDraft  bool              // Bound to the draft form
+	draft        *draft            // The draft found at startup
+	draftValues  map[string]string // Its values for the current fields, secrets opened when possible
+	draftKeys    []string          // The keys of draftValues in template order
+	draftMissing []string          // Secrets of the draft that could not be opened or were left out
+
+	// Fields for the "what's new" step shown when the template changed since the last run
+	offerScope    bool // The template changed since the stamped run, ask before the main form
+	choosingScope bool
+	scopeForm     *huh.Form
+	changedKeys   []string // Keys that are new or changed since the stamped schema
+
+	// Fields for searching and filtering the main form
+	filtering     bool // The search bar has focus
+	filterInput   textinput.Model
+	filterScope   filterScope
+	filterMatches int
+
+	// How git sees .env, checked before writing
+	git           gitStatus
+	ignoreEntries []string // .gitignore entries offered for .env and its companion files
+	addIgnore     bool     // The user accepted the ignore entries
+
+	leaksAcknowledged bool // The user chose to save values the leak detectors warned about
+
+	checks map[string]checkResult // Latest @check result of each variable
+
+	// Fields for the parts form of url and dsn variables
+	partsForm     *huh.Form
+	parts         *urlParts // Bound to the parts form
+	partsOriginal urlParts  // The parts when the form opened
+	partsField    int       // Index of the field being edited
+
+	// Fields for the file picker of path, file and dir variables
+	pickerForm  *huh.Form
+	picked      string // Bound to the file picker
+	pickerField int    // Index of the field being edited
+
+	createDirs bool // The user chose to create the missing @type=dir directories on save
+
+	// Session history for undo and redo across all fields
+	undoStack      []edit
+	redoStack      []edit   // Undone edits, the latest last
+	snapshot       []string // Field values as of the last recorded edit, parallel to fields
+	typing         int      // Field whose latest edit is still being typed, or -1
+	historyStatus  string   // Result of the last undo or redo, shown until the next key
+	showingChanges bool     // The "changes so far" overlay is open
+
+	// Fields for the quit confirmation shown when leaving the main form with unsaved edits
+	quitForm      *huh.Form
+	quitConfirmed bool // Bound to the quit form
+	showingHelp   bool // The key binding overlay is open
+
 	// Fields for confirmation step
 	confirming      bool
 	confirmForm     *huh.Form
 	envValuesToSave map[string]string
 	applyChanges    bool   // To store the result of the confirm form
 	diffSummary     string // To store the formatted diff for display
+	changes         []change
+	accepted        []bool // Per-change selection in the review, parallel to changes
+	reviewCursor    int
 }
 
 func initialModel() *model {
+	return newModel(options{})
+}
+
+func newModel(opts options) *model {
+	if opts.leaks == nil {
+		opts.leaks = defaultLeakRules()
+	}
+	keys := defaultKeyMap()
+	if opts.keys != nil {
+		keys = *opts.keys
+	}
 	return &model{
+		opts:         opts,
+		keys:         keys,
+		filterInput:  newFilterInput(),
 		applyChanges: true, // Default to true, will be set by confirm form
 	}
 }
 
 func (m *model) Init() tea.Cmd {
-	var err error
-	m.envVars, err = readEnvVarsFromFile(".env.example")
-	if err != nil {
-		m.err = fmt.Errorf("Error reading .env.example: %w. Please create one to use as a template.", err)
-		return tea.Quit
-	}
-	if len(m.envVars) == 0 {
-		m.err = fmt.Errorf("No environment variables found in .env.example.")
-		return tea.Quit
+	// With several services found by --recursive, ask which ones to set up first
+	if len(m.opts.services) > 1 && !m.opts.dryRun {
+		m.prepareServiceForm()
+		return m.serviceForm.Init()
 	}
+	return m.load()
+}
 
-	m.existingEnvValues, err = readExistingEnvFile(".env")
-	if err != nil {
-		fmt.Printf("Warning: could not read existing .env file to prefill: %v\n", err)
-		m.existingEnvValues = make(map[string]string)
+// load reads the template and .env files and builds the form fields
+func (m *model) load() tea.Cmd {
+	var stamped bool
+	if len(m.opts.services) > 0 {
+		if err := m.loadServices(); err != nil {
+			m.err = err
+			return tea.Quit
+		}
+		m.checkGitFiles()
+		m.changedKeys, stamped = m.servicesChangedKeys()
+	} else {
+		var err error
+		m.envVars, err = readEnvVarsFromFile(templateFilePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				err = errTemplateMissing
+			}
+			m.err = fmt.Errorf("Error reading %s: %w. Please create one to use as a template.", templateFilePath, err)
+			return tea.Quit
+		}
+		if len(m.envVars) == 0 {
+			m.err = fmt.Errorf("No environment variables found in %s: %w.", templateFilePath, errTemplateEmpty)
+			return tea.Quit
+		}
+
+		m.existingEnvValues, err = readExistingEnvFile(envOutputFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not read existing %s file to prefill: %v\n", envOutputFilePath, err)
+			m.existingEnvValues = make(map[string]string)
+		}
+		if _, statErr := os.Stat(envOutputFilePath); statErr == nil {
+			m.envFileExists = true
+		}
+
+		m.checkGitFiles()
+
+		stamp, err := readSchemaStamp(stampFilePath(envOutputFilePath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not read schema stamp: %v\n", err)
+		}
+		if stamp != nil {
+			m.changedKeys, stamped = stamp.changedKeys(m.envVars), true
+		}
 	}
 
 	m.fields = make([]huh.Field, 0, len(m.envVars))
+	m.initialValues = make([]string, 0, len(m.envVars))
+	prefillOrder := m.opts.prefillOrder
+	if len(prefillOrder) == 0 {
+		prefillOrder = defaultPrefillOrder
+	}
+	sources := prefillSources{sourceDotEnv: m.existingEnvValues, sourceEncrypted: m.opts.encrypted}
+	for _, source := range prefillOrder {
+		if source == sourceEnvironment {
+			m.processEnv = environValues(os.Environ())
+			sources[sourceEnvironment] = m.processEnv
+		}
+	}
+
+	m.sources = make([]valueSource, 0, len(m.envVars))
+	m.titles = make([]string, len(m.envVars))
 	for _, envVar := range m.envVars {
 		localKey := envVar.Key
-		initialValue, valueExistsInEnv := m.existingEnvValues[localKey]
-		if !valueExistsInEnv || initialValue == "" {
-			if envVar.ExampleValue != "" {
-				initialValue = envVar.ExampleValue
-			}
+		i := len(m.fields)
+		initialValue, source := resolveInitialValue(envVar, prefillOrder, sources)
+		if answer, ok := m.opts.answers[localKey]; ok {
+			initialValue, source = answer, sourceAnswers
 		}
 		fieldValuePtr := new(string)
 		*fieldValuePtr = initialValue
 
 		inputField := huh.NewInput().
+			Key(localKey).
+			Validate(func(value string) error { return m.validate(i, value) }).
 			Title(localKey).
 			Value(fieldValuePtr)
 
-		if envVar.Description != "" {
-			inputField = inputField.Description(envVar.Description)
+		description := envVar.Description
+		if services := m.usedBy[localKey]; len(services) > 1 {
+			description = strings.TrimSpace(description + " (shared by " + strings.Join(services, ", ") + ")")
+		}
+		if description != "" {
+			inputField = inputField.Description(description)
 		}
 		m.fields = append(m.fields, inputField)
+		m.initialValues = append(m.initialValues, initialValue)
+		m.sources = append(m.sources, source)
+	}
+	m.snapshot = slices.Clone(m.initialValues)
+	m.typing = -1
+	checkCmd := tea.Batch(m.checkCmds()...)
+	m.refreshBadges()
+	for key := range m.opts.answers {
+		if !containsKey(m.envVars, key) {
+			fmt.Fprintf(os.Stderr, "Warning: answers file sets %s, which is not in .env.example\n", key)
+		}
 	}
 
-	customKeyMap := huh.NewDefaultKeyMap()
-	customKeyMap.Quit = key.NewBinding(key.WithKeys("esc", "ctrl+c"), key.WithHelp("esc/ctrl+c", "quit"))
-	customKeyMap.Input.Next = key.NewBinding(key.WithKeys("enter", "tab", "down"), key.WithHelp("enter/tab/↓", "next"))
-	customKeyMap.Input.Prev = key.NewBinding(key.WithKeys("shift+tab", "up"), key.WithHelp("shift+tab/↑", "prev"))
+	m.offerScope = stamped && len(m.changedKeys) > 0 && len(m.changedKeys) < len(m.envVars) && !m.opts.onlyNew
 
-	m.form = huh.NewForm(
-		huh.NewGroup(m.fields...).
-			Title("Setup your .env values"),
-	).WithTheme(huh.ThemeCharm()).WithKeyMap(customKeyMap).WithWidth(80)
+	// Offer the answers of a cancelled run before anything else
+	if !m.opts.dryRun && m.prepareDraftForm() {
+		return tea.Batch(m.draftForm.Init(), checkCmd)
+	}
+	cmd := m.showForm()
+	if m.err != nil || m.quitting {
+		return cmd
+	}
+	return tea.Batch(cmd, checkCmd)
+}
 
+// showForm moves on to the first step after loading: the review when everything was
+// answered up front, the "what's new" step, or the main form
+func (m *model) showForm() tea.Cmd {
+	// Everything was answered up front, go straight to the review
+	if len(m.visibleFieldIndices()) == 0 && !m.filterActive() {
+		if err := m.prepareForConfirmation(); err != nil {
+			m.err = err
+			return tea.Quit
+		}
+		if m.quitting {
+			return tea.Quit
+		}
+		return m.confirmForm.Init()
+	}
+
+	// Offer the "what's new" view when the template changed since the last stamped run
+	if m.offerScope {
+		m.prepareScopeForm()
+		return m.scopeForm.Init()
+	}
+
+	m.buildMainForm()
 	return m.form.Init()
 }
 
+// visibleFieldIndices returns the indices of the fields shown in the main form.
+// Hidden fields keep their values and are still saved.
+func (m *model) visibleFieldIndices() []int {
+	changed := make(map[string]bool, len(m.changedKeys))
+	for _, key := range m.changedKeys {
+		changed[key] = true
+	}
+	onlyNew := m.opts.onlyNew && len(m.changedKeys) > 0
+
+	var indices []int
+	for i, envVar := range m.envVars {
+		if onlyNew && !changed[envVar.Key] {
+			continue
+		}
+		if _, answered := m.opts.answers[envVar.Key]; answered {
+			continue
+		}
+		if !m.matchesFilter(i) {
+			continue
+		}
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+// buildMainForm creates the main form from the visible fields
+func (m *model) buildMainForm() {
+	indices := m.visibleFieldIndices()
+	if len(indices) == 0 {
+		indices = make([]int, len(m.fields))
+		for i := range m.fields {
+			indices[i] = i
+		}
+	}
+	m.filterMatches = len(indices)
+	fields := make([]huh.Field, 0, len(indices))
+	for _, i := range indices {
+		fields = append(fields, m.fields[i])
+	}
+
+	title := fmt.Sprintf("Setup your %s values", envOutputFilePath)
+	if m.opts.onlyNew && len(m.changedKeys) > 0 {
+		title += " (new and changed only)"
+	}
+
+	m.form = huh.NewForm(
+		huh.NewGroup(fields...).
+			Title(title),
+	).WithTheme(m.theme()).WithKeyMap(m.keys.formKeyMap())
+	m.applyLayout()
+}
+
+// prepareScopeForm asks whether to review only the variables that changed since the last run
+func (m *model) prepareScopeForm() {
+	m.opts.onlyNew = true // Default to the short list, the confirm field below can turn it off
+	scopeField := huh.NewConfirm().
+		Title(fmt.Sprintf("%d of %d variables are new or changed since your last run", len(m.changedKeys), len(m.envVars))).
+		Description(strings.Join(m.changedKeys, ", ")).
+		Affirmative("Only show those").
+		Negative("Show all").
+		Value(&m.opts.onlyNew)
+
+	m.scopeForm = huh.NewForm(
+		huh.NewGroup(scopeField).Title("What's new in " + filepath.Base(templateFilePath)),
+	).WithTheme(m.theme()).WithKeyMap(m.keys.formKeyMap())
+	m.choosingScope = true
+	m.applyLayout()
+}
+
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if m.err != nil {
 		return m, tea.Quit
@@ -107,22 +390,139 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		// Optionally, update form widths if they support it
-		// m.form = m.form.WithWidth(m.width)
-		// if m.confirmForm != nil {
-		// 	m.confirmForm = m.confirmForm.WithWidth(m.width)
-		// }
+		m.applyLayout()
+
+	case checkResultMsg:
+		m.applyCheckResult(msg)
+		return m, nil
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
+		if m.quitsNow(msg) {
+			m.saveDraft()
 			m.quitting = true
+			m.aborted = true
 			return m, tea.Quit
 		}
 
+		// The overlays, the search bar and the sub-forms take every key while they are open
+		if m.showingHelp {
+			m.updateHelpOverlay(msg)
+			return m, nil
+		}
+		if !m.filtering && m.quitForm == nil && key.Matches(msg, m.keys.ShowHelp) {
+			m.showingHelp = true
+			return m, nil
+		}
+		if m.showingChanges {
+			m.updateChangesOverlay(msg)
+			return m, nil
+		}
+		if m.fields != nil && !m.filtering && key.Matches(msg, m.keys.ShowChanges) {
+			m.showingChanges = true
+			return m, nil
+		}
+		if m.filtering {
+			return m, m.updateFilter(msg)
+		}
+		if m.quitForm != nil {
+			return m, m.updateQuitForm(msg)
+		}
+		if m.partsForm != nil {
+			return m, m.updatePartsForm(msg)
+		}
+		if m.pickerForm != nil {
+			return m, m.updatePathPicker(msg)
+		}
+		if m.form != nil && !m.choosingScope && !m.confirming && key.Matches(msg, m.keys.Filter) {
+			m.filtering = true
+			return m, m.filterInput.Focus()
+		}
+		if m.form != nil && !m.choosingScope && !m.confirming {
+			m.historyStatus = ""
+			switch {
+			case key.Matches(msg, m.keys.Undo):
+				m.undo()
+				return m, nil
+			case key.Matches(msg, m.keys.Redo):
+				m.redo()
+				return m, nil
+			case key.Matches(msg, m.keys.ResetDefault):
+				m.resetToDefault()
+				return m, nil
+			case key.Matches(msg, m.keys.RestoreOriginal):
+				m.restoreOriginal()
+				return m, nil
+			case key.Matches(msg, m.keys.EditParts) && m.openPartsForm():
+				return m, m.partsForm.Init()
+			case key.Matches(msg, m.keys.Browse) && m.openPathPicker():
+				return m, m.pickerForm.Init()
+			case key.Matches(msg, m.keys.Quit) && m.openQuitForm():
+				return m, m.quitForm.Init()
+			}
+		}
+		// The change list takes its own keys, everything else goes to the confirm form
+		if m.confirming {
+			if handled, cmd := m.updateReview(msg); handled {
+				return m, cmd
+			}
+		}
 	}
 
-	if m.confirming {
+	if m.choosingServices {
+		newServiceForm, serviceCmd := m.serviceForm.Update(msg)
+		if sf, ok := newServiceForm.(*huh.Form); ok {
+			m.serviceForm = sf
+			cmds = append(cmds, serviceCmd)
+		}
+
+		if m.serviceForm.State == huh.StateCompleted {
+			m.choosingServices = false
+			cmds = append(cmds, m.load(), tea.WindowSize())
+		}
+		if m.serviceForm.State == huh.StateAborted {
+			fmt.Println("\nOperation cancelled by user.") // This will print after TUI exits
+			m.quitting = true
+			m.aborted = true
+			return m, tea.Quit
+		}
+	} else if m.draftForm != nil {
+		newDraftForm, draftCmd := m.draftForm.Update(msg)
+		if df, ok := newDraftForm.(*huh.Form); ok {
+			m.draftForm = df
+			cmds = append(cmds, draftCmd)
+		}
+
+		if m.draftForm.State == huh.StateCompleted {
+			cmds = append(cmds, m.resolveDraft(), tea.WindowSize())
+			if m.err != nil || m.quitting {
+				return m, tea.Quit
+			}
+		} else if m.draftForm.State == huh.StateAborted {
+			fmt.Println("\nOperation cancelled by user.") // This will print after TUI exits
+			m.quitting = true
+			m.aborted = true
+			return m, tea.Quit
+		}
+	} else if m.choosingScope {
+		newScopeForm, scopeCmd := m.scopeForm.Update(msg)
+		if sf, ok := newScopeForm.(*huh.Form); ok {
+			m.scopeForm = sf
+			cmds = append(cmds, scopeCmd)
+		}
+
+		if m.scopeForm.State == huh.StateCompleted {
+			m.choosingScope = false
+			m.buildMainForm()
+			cmds = append(cmds, m.form.Init())
+		}
+		if m.scopeForm.State == huh.StateAborted {
+			fmt.Println("\nOperation cancelled by user.") // This will print after TUI exits
+			m.saveDraft()
+			m.quitting = true
+			m.aborted = true
+			return m, tea.Quit
+		}
+	} else if m.confirming {
 		// Process confirmation form
 		newConfirmForm, confirmCmd := m.confirmForm.Update(msg)
 		if cf, ok := newConfirmForm.(*huh.Form); ok {
@@ -132,22 +532,37 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		if m.confirmForm.State == huh.StateCompleted {
 			// Confirmation received, m.applyChanges holds the boolean result
-			if m.applyChanges {
-				err := m.actuallyWriteEnvFile(m.envValuesToSave)
+			if m.applyChanges && len(m.changes) > 0 && m.acceptedCount() == 0 {
+				fmt.Printf("\nNo changes selected, %s left untouched.\n", envOutputFilePath) // This will print after TUI exits
+			} else if m.applyChanges && m.hasLeakWarnings() && !m.leaksAcknowledged {
+				fmt.Println("\nNot saved: the credential warnings were not acknowledged.") // This will print after TUI exits
+				m.aborted = true
+			} else if m.applyChanges {
+				err := m.save()
 				if err != nil {
 					m.err = err // Store error to display it after TUI exits
 				}
 			} else {
 				fmt.Println("\nChanges discarded by user.") // This will print after TUI exits
+				m.removeDraft()
+				m.aborted = true
 			}
 			m.quitting = true
 			return m, tea.Quit
 		}
 		if m.confirmForm.State == huh.StateAborted {
 			fmt.Println("\nSave operation cancelled by user.") // This will print after TUI exits
+			m.saveDraft()
 			m.quitting = true
+			m.aborted = true
 			return m, tea.Quit
 		}
+	} else if m.quitForm != nil {
+		cmds = append(cmds, m.updateQuitForm(msg))
+	} else if m.partsForm != nil {
+		cmds = append(cmds, m.updatePartsForm(msg))
+	} else if m.pickerForm != nil {
+		cmds = append(cmds, m.updatePathPicker(msg))
 	} else {
 		// Process main form
 		newMainForm, mainCmd := m.form.Update(msg)
@@ -155,11 +570,17 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.form = mf
 			cmds = append(cmds, mainCmd)
 		}
+		m.trackEdits()
+		m.refreshBadges()
+		cmds = append(cmds, m.checkCmds()...)
 
 		if m.form.State == huh.StateCompleted {
 			// Main form completed, now switch to confirmation state
 			err := m.prepareForConfirmation() // This will set m.confirming = true and m.diffSummary
 			if err != nil {
+				if errors.Is(err, errValidation) {
+					m.saveDraft() // Keep the answers of a form that hid an invalid field
+				}
 				m.err = err
 				m.quitting = true
 				return m, tea.Quit
@@ -170,12 +591,14 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Do not quit yet, stay in confirming state.
 			// We need to return Init for the confirmForm
 			if m.confirming && m.confirmForm != nil {
-				cmds = append(cmds, m.confirmForm.Init())
+				cmds = append(cmds, m.confirmForm.Init(), tea.WindowSize())
 			}
 		}
 		if m.form.State == huh.StateAborted {
 			fmt.Println("\nOperation cancelled by user (main form aborted).") // This will print after TUI exits
+			m.saveDraft()
 			m.quitting = true
+			m.aborted = true
 			return m, tea.Quit
 		}
 	}
@@ -183,6 +606,14 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// exitErr returns the error that decides the exit status once the program has finished
+func (m *model) exitErr() error {
+	if m.err == nil && m.aborted {
+		return errAborted
+	}
+	return m.err
+}
+
 func (m *model) View() string {
 	if m.err != nil {
 		return fmt.Sprintf("\nError: %v\n", m.err)
@@ -191,104 +622,227 @@ func (m *model) View() string {
 		return ""
 	}
 
+	if m.showingHelp {
+		return m.helpView()
+	}
+	if m.showingChanges {
+		return m.changesView()
+	}
+	if m.choosingServices && m.serviceForm != nil {
+		return m.serviceForm.View()
+	}
+	if m.draftForm != nil {
+		return m.draftForm.View()
+	}
+	if m.choosingScope && m.scopeForm != nil {
+		return m.scopeForm.View()
+	}
 	if m.confirming && m.confirmForm != nil {
 		// Display diff summary above the confirmation form
-		return fmt.Sprintf("Proposed changes:\n%s\n\n%s", m.diffSummary, m.confirmForm.View())
+		diffSummary := m.diffSummary
+		if m.width > 0 {
+			diffSummary = lipgloss.NewStyle().Width(m.width).Render(diffSummary)
+		}
+		if len(m.changes) > 0 {
+			diffSummary = m.reviewView()
+		}
+		view := fmt.Sprintf("Proposed changes:\n%s\n\n%s", diffSummary, m.confirmForm.View())
+		if warning := m.saveWarning(); warning != "" {
+			view = m.palette().Error.Width(m.width).Render(warning) + "\n\n" + view
+		}
+		return view
+	}
+	if m.quitForm != nil {
+		return m.quitForm.View()
+	}
+	if m.partsForm != nil {
+		return m.partsView()
+	}
+	if m.pickerForm != nil {
+		return m.pickerForm.View()
 	}
 	// For the main form
-	return m.form.View()
+	return m.mainView()
 }
 
 // prepareForConfirmation collects values and sets up the confirmation form
 func (m *model) prepareForConfirmation() error {
-	collectedEnvValues := make(map[string]string)
-	for i, envVar := range m.envVars {
-		inputField, ok := m.fields[i].(*huh.Input)
-		if !ok {
-			return fmt.Errorf("error: could not cast field for key %s to huh.Input", envVar.Key)
-		}
-		val := inputField.GetValue().(string)
-		collectedEnvValues[envVar.Key] = val
+	collectedEnvValues, err := m.collectValues()
+	if err != nil {
+		return err
 	}
-
-	var diffLines []string
-	changed := false
-	for _, envVar := range m.envVars {
-		key := envVar.Key
-		oldValue, oldExists := m.existingEnvValues[key]
-		newValue := collectedEnvValues[key]
-		if !oldExists && newValue != "" {
-			diffLines = append(diffLines, fmt.Sprintf("+ Added: %s=\"%s\"", key, newValue))
-			changed = true
-		} else if oldExists && newValue != oldValue {
-			if newValue == "" {
-				diffLines = append(diffLines, fmt.Sprintf("~ Cleared: %s (was \"%s\")", key, oldValue))
-			} else {
-				diffLines = append(diffLines, fmt.Sprintf("~ Changed: %s: \"%s\" -> \"%s\"", key, oldValue, newValue))
-			}
-			changed = true
-		} else if !oldExists && newValue == "" {
-			// This case handles adding an empty value where none existed.
-			// It's debatable if this should count as "changed" if the default behavior is an empty string.
-			// For now, let's consider it a change to be explicit.
-			diffLines = append(diffLines, fmt.Sprintf("+ Added: %s=\"\"", key))
-			changed = true
+	if _, errs := m.validateAll(collectedEnvValues); len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
 		}
+		return fmt.Errorf("%w: %s", errValidation, strings.Join(messages, "; "))
 	}
 
+	changes := m.changeSet(collectedEnvValues)
+	changed := len(changes) > 0
+
 	if !changed {
+		m.writeStamp()
+		m.removeDraft()
+		m.recordIfRequested(collectedEnvValues)
 		// fmt.Println("\nNo changes to apply to .env file.") // This would be overwritten
-		m.diffSummary = "No changes to apply to .env file." // Store for potential display or just quit
-		m.quitting = true                                   // No changes, so we can quit directly
+		m.diffSummary = noChangesMessage() // Store for potential display or just quit
+		m.quitting = true                  // No changes, so we can quit directly
 		return nil
 	}
 
 	// Store values and prepare confirmation form
 	m.envValuesToSave = collectedEnvValues
-	m.diffSummary = strings.Join(diffLines, "\n") // Store formatted diff
+	m.changes = changes
+	m.accepted = make([]bool, len(changes))
+	for i := range changes {
+		m.accepted[i] = true
+	}
+	m.diffSummary = formatChanges(changes) // Store formatted diff
+	m.reviewCursor = 0
+
+	saveLabel := "Save to " + envOutputFilePath
+	if len(m.services) > 1 {
+		saveLabel = fmt.Sprintf("Save to %d .env files", len(m.services))
+	}
 
 	// m.applyChanges is already true by default, huh.Confirm will set it to false if "No"
 	confirmField := huh.NewConfirm().
 		Title("Save these changes?").
-		Affirmative("Save to .env"). // Text for Yes
+		Affirmative(saveLabel).      // Text for Yes
 		Negative("Discard changes"). // Text for No
 		Value(&m.applyChanges)       // Bind to model field
 
-	confirmKeyMap := huh.NewDefaultKeyMap()
+	confirmFields := []huh.Field{confirmField}
+	if m.hasLeakWarnings() {
+		m.leaksAcknowledged = false
+		leakField := huh.NewConfirm().
+			Title("Some values look like leaked or production credentials").
+			Description("Check the ⚠ changes above. Save them anyway?").
+			Affirmative("Save anyway").
+			Negative("Don't save").
+			Value(&m.leaksAcknowledged)
+		confirmFields = append([]huh.Field{leakField}, confirmFields...)
+	}
+	if _, dirs := m.missingPaths(); len(dirs) > 0 {
+		m.createDirs = true
+		dirsField := huh.NewConfirm().
+			Title("Create the missing directories?").
+			Description(strings.Join(dirs, " ")).
+			Affirmative("Create them").
+			Negative("Leave them").
+			Value(&m.createDirs)
+		confirmFields = append([]huh.Field{dirsField}, confirmFields...)
+	}
+	if len(m.ignoreEntries) > 0 {
+		m.addIgnore = true
+		ignoreField := huh.NewConfirm().
+			Title("Add to .gitignore?").
+			Description("Not ignored by git yet: " + strings.Join(m.ignoreEntries, " ")).
+			Affirmative("Add to .gitignore").
+			Negative("Leave .gitignore").
+			Value(&m.addIgnore)
+		confirmFields = append([]huh.Field{ignoreField}, confirmFields...)
+	}
 
 	m.confirmForm = huh.NewForm(
-		huh.NewGroup(confirmField).Title("Confirmation"),
-	).WithTheme(huh.ThemeCharm()).WithKeyMap(confirmKeyMap).WithWidth(60) // Adjust width as needed
+		huh.NewGroup(confirmFields...).Title("Confirmation"),
+	).WithTheme(m.theme()).WithKeyMap(m.keys.formKeyMap())
 
 	m.confirming = true
+	m.applyLayout()
 	return nil
 }
 
+// save writes the accepted changes, to every service with --recursive, and drops the draft
+// they may have been resumed from
+func (m *model) save() error {
+	var err error
+	if len(m.services) > 0 {
+		err = m.writeServices()
+	} else {
+		err = m.actuallyWriteEnvFile(m.acceptedValues())
+	}
+	if err == nil {
+		m.removeDraft()
+		m.recordIfRequested(m.acceptedValues())
+	}
+	return err
+}
+
 // actuallyWriteEnvFile performs the file writing operations
 func (m *model) actuallyWriteEnvFile(envValues map[string]string) error {
+	if keys := trackedSecrets(m.git, m.envVars, envValues, m.opts.force); len(keys) > 0 {
+		return fmt.Errorf("%w: %s would contain %s. Untrack it with `git rm --cached %s` or rerun with --force",
+			errTrackedFile, m.git.Path, strings.Join(keys, ", "), m.git.Path)
+	}
+	if err := m.writeEnvFileAt(envOutputFilePath, m.envVars, envValues); err != nil {
+		return err
+	}
+	m.updateGitignore()
+	return nil
+}
+
+// writeEnvFileAt backs up an existing .env at envPath, writes the values and stamps the template
+func (m *model) writeEnvFileAt(envPath string, envVars []EnvVar, envValues map[string]string) error {
 	// These fmt.Println calls will appear after the TUI exits
-	info, statErr := os.Stat(".env")
-	if statErr == nil {
+	info, statErr := os.Stat(envPath)
+	if statErr == nil && m.opts.backup != backupNone {
 		if !info.IsDir() {
-			fmt.Println("\nBacking up existing .env to .env.old...")
-			backupErr := backupEnvFile(".env", ".env.old")
+			backupPath := m.backupPath(envPath)
+			fmt.Printf("\nBacking up existing %s to %s...\n", envPath, backupPath)
+			backupErr := backupEnvFile(envPath, backupPath)
 			if backupErr != nil {
-				fmt.Printf("Warning: Failed to backup .env: %v\n", backupErr)
+				fmt.Printf("Warning: Failed to backup %s: %v\n", envPath, backupErr)
 			} else {
-				fmt.Println("Successfully backed up .env to .env.old.")
+				fmt.Printf("Successfully backed up %s to %s.\n", envPath, backupPath)
 			}
 		} else {
-			fmt.Printf("Warning: .env exists but is a directory. Skipping backup.\n")
+			fmt.Printf("Warning: %s exists but is a directory. Skipping backup.\n", envPath)
 		}
-	} else if !os.IsNotExist(statErr) {
-		fmt.Printf("Warning: Error checking .env for backup: %v\n", statErr)
+	} else if statErr != nil && !os.IsNotExist(statErr) {
+		fmt.Printf("Warning: Error checking %s for backup: %v\n", envPath, statErr)
 	}
 
-	err := writeEnvFile(envValues)
+	err := writeEnvFileTo(envPath, envValues)
 	if err != nil {
-		fmt.Printf("\nError writing .env file: %v\n", err)
-		return fmt.Errorf("error writing .env file: %w", err)
+		fmt.Printf("\nError writing %s file: %v\n", envPath, err)
+		return fmt.Errorf("%w: %w", errWriteFailed, err)
+	}
+	m.writeStampFor(envPath, envVars)
+	fmt.Printf("\n✅ Successfully updated the %s file!\n", envPath)
+	if m.createDirs {
+		createMissingDirs(filepath.Dir(envPath), envVars, envValues)
 	}
-	fmt.Println("\n✅ Successfully updated the .env file!")
 	return nil
 }
+
+// backupPath returns where the existing .env at envPath is copied before it is overwritten
+func (m *model) backupPath(envPath string) string {
+	if m.opts.backup == backupTimestamped {
+		return envPath + ".old." + time.Now().Format("20060102-150405")
+	}
+	return envPath + ".old"
+}
+
+// writeStamp records the applied template schema next to the .env file, or next to the
+// .env of every service with --recursive
+func (m *model) writeStamp() {
+	if len(m.services) == 0 {
+		m.writeStampFor(envOutputFilePath, m.envVars)
+	}
+	for _, s := range m.services {
+		m.writeStampFor(s.EnvFile, s.envVars)
+	}
+}
+
+// writeStampFor records the schema of envVars next to the .env at envPath
+func (m *model) writeStampFor(envPath string, envVars []EnvVar) {
+	if m.opts.dryRun || len(envVars) == 0 {
+		return
+	}
+	if err := writeSchemaStamp(stampFilePath(envPath), newSchemaStamp(envVars)); err != nil {
+		fmt.Printf("Warning: could not write schema stamp: %v\n", err)
+	}
+}
@@ -24,6 +24,22 @@ func createTempFileForModel(t *testing.T, dir string, fileName string, content s
 	return filePath
 }
 
+// chdirTemp creates files, relative paths mapped to their content, in a new temporary
+// directory and changes into it until the test ends. It returns the directory.
+func chdirTemp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		createTempFileForModel(t, dir, name, content)
+	}
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(originalWd) })
+	return dir
+}
+
 func TestInitialModel(t *testing.T) {
 	m := initialModel()
 	if m == nil {
@@ -280,12 +296,9 @@ func TestModelUpdate(t *testing.T) {
 		// For now, we'll check that it *would* try to write.
 		// We need to ensure `envOutputFilePath` is set for `actuallyWriteEnvFile` if it were called.
 		originalEnvOutputFilePath := envOutputFilePath
-		testOutputFile := filepath.Join(wd, "test_output.env") // wd is tmpDir here
+		testOutputFile := filepath.Join(t.TempDir(), "test_output.env") // The stamp is written next to it
 		envOutputFilePath = testOutputFile
-		defer func() {
-			envOutputFilePath = originalEnvOutputFilePath
-			os.Remove(testOutputFile)
-		}()
+		defer func() { envOutputFilePath = originalEnvOutputFilePath }()
 
 		updatedModel, cmd := m.Update(nil) // Msg doesn't matter as state is forced
 
@@ -606,6 +619,60 @@ func TestActuallyWriteEnvFile(t *testing.T) {
 	// Error during backup is implicitly covered by backupEnvFile tests.
 }
 
+func TestModelWhatsNew(t *testing.T) {
+	setupStampedDir := func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "KEY1=v1\nKEY2=v2 # added later", ".env": "KEY1=v1"})
+		stamp := newSchemaStamp([]EnvVar{{Key: "KEY1", ExampleValue: "v1"}})
+		require.NoError(t, writeSchemaStamp(".env.stamp", stamp))
+	}
+
+	t.Run("offers the new and changed view", func(t *testing.T) {
+		setupStampedDir(t)
+
+		m := initialModel()
+		cmd := m.Init()
+		require.Nil(t, m.err)
+		require.NotNil(t, cmd)
+		assert.Equal(t, []string{"KEY2"}, m.changedKeys)
+		assert.True(t, m.choosingScope, "Should ask which variables to show")
+		require.NotNil(t, m.scopeForm)
+		assert.Nil(t, m.form, "Main form should wait for the scope choice")
+		assert.Contains(t, m.View(), "new or changed since your last run")
+
+		m.scopeForm.State = huh.StateCompleted
+		updatedModel, _ := m.Update(nil)
+		mu := updatedModel.(*model)
+		assert.False(t, mu.choosingScope)
+		require.NotNil(t, mu.form)
+		assert.Contains(t, mu.View(), "new and changed only")
+		assert.Len(t, mu.fields, 2, "All fields are kept so unchanged values are still saved")
+	})
+
+	t.Run("only-new option skips the question", func(t *testing.T) {
+		setupStampedDir(t)
+
+		m := newModel(options{onlyNew: true})
+		_ = m.Init()
+		require.Nil(t, m.err)
+		assert.False(t, m.choosingScope)
+		require.NotNil(t, m.form)
+		assert.Contains(t, m.View(), "new and changed only")
+	})
+
+	t.Run("writing records the stamp", func(t *testing.T) {
+		setupStampedDir(t)
+
+		m := newModel(options{onlyNew: true})
+		_ = m.Init()
+		require.NoError(t, m.actuallyWriteEnvFile(map[string]string{"KEY1": "v1", "KEY2": "v2"}))
+
+		stamp, err := readSchemaStamp(".env.stamp")
+		require.NoError(t, err)
+		require.NotNil(t, stamp)
+		assert.Empty(t, stamp.changedKeys(m.envVars))
+	})
+}
+
 // Note: Testing functions that directly print to console (like some parts of Update or Init warnings)
 // is harder and often involves redirecting stdout, which can be flaky.
 // We've focused on state changes and returned commands/errors.
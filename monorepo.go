@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+// service is a directory with its own template, set up together with the others by --recursive
+type service struct {
+	Name     string // Directory relative to the root of the search, "." for the root itself
+	Template string
+	EnvFile  string // Written next to the template
+
+	envVars       []EnvVar
+	existing      map[string]string
+	envFileExists bool
+	git           gitStatus
+	stamp         *schemaStamp // Template applied to EnvFile by the last run, nil without one
+}
+
+// discoverServices finds every template named like templateName below root, skipping .git and
+// anything git ignores. The .env of each service is envName in the same directory.
+func discoverServices(root, templateName, envName string) ([]service, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	gitRoot, gitDir, err := findGitDir(abs)
+	if err != nil {
+		return nil, err
+	}
+	rulesByDir := make(map[string][]ignoreRule)
+	ignored := func(file string, isDir bool) (bool, error) {
+		if gitRoot == "" {
+			return false, nil
+		}
+		rel, err := filepath.Rel(gitRoot, file)
+		if err != nil {
+			return false, err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return false, nil
+		}
+		dir := path.Dir(rel)
+		rules, ok := rulesByDir[dir]
+		if !ok {
+			if rules, err = loadIgnoreRules(gitRoot, gitDir, rel); err != nil {
+				return false, err
+			}
+			rulesByDir[dir] = rules
+		}
+		return isIgnoredPath(rules, rel, isDir), nil
+	}
+
+	var services []service
+	err = filepath.WalkDir(abs, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !entry.IsDir() && entry.Name() != templateName {
+			return nil
+		}
+		skip, err := ignored(file, entry.IsDir())
+		switch {
+		case err != nil:
+			return err
+		case skip && entry.IsDir():
+			return filepath.SkipDir
+		case skip || entry.IsDir():
+			return nil
+		}
+		dir, err := filepath.Rel(abs, filepath.Dir(file))
+		if err != nil {
+			return err
+		}
+		services = append(services, service{
+			Name:     filepath.ToSlash(dir),
+			Template: filepath.Join(root, dir, templateName),
+			EnvFile:  filepath.Join(root, dir, envName),
+		})
+		return nil
+	})
+	return services, err
+}
+
+// load reads the template, the existing .env and the git status of the service
+func (s *service) load() error {
+	var err error
+	if s.envVars, err = readEnvVarsFromFile(s.Template); err != nil {
+		return fmt.Errorf("Error reading %s: %w", s.Template, err)
+	}
+	if len(s.envVars) == 0 {
+		return fmt.Errorf("No environment variables found in %s: %w.", s.Template, errTemplateEmpty)
+	}
+	if s.existing, err = readExistingEnvFile(s.EnvFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read %s to prefill: %v\n", s.EnvFile, err)
+		s.existing = make(map[string]string)
+	}
+	if _, statErr := os.Stat(s.EnvFile); statErr == nil {
+		s.envFileExists = true
+	}
+	if s.git, err = checkGitStatus(s.EnvFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not check %s against git: %v\n", s.EnvFile, err)
+	}
+	if s.stamp, err = readSchemaStamp(stampFilePath(s.EnvFile)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read schema stamp: %v\n", err)
+	}
+	return nil
+}
+
+// mergeServices combines the templates of the services into one list of fields, so a variable
+// used by several services is asked once. The first service defining a key supplies its
+// description and example; it is required or secret if any service says so. Existing values
+// are taken from the first .env holding the key. A key whose .env values or example defaults
+// differ between services is asked once per service instead, under serviceFieldKey.
+// usedBy lists the services of every field.
+func mergeServices(services []service) (envVars []EnvVar, existing map[string]string, usedBy map[string][]string) {
+	existing = make(map[string]string)
+	usedBy = make(map[string][]string)
+	index := make(map[string]int)
+	split := disagreeingKeys(services)
+	for _, s := range services {
+		for _, envVar := range s.envVars {
+			if split[envVar.Key] {
+				field := serviceFieldKey(s.Name, envVar.Key)
+				usedBy[field] = []string{s.Name}
+				if value, ok := s.existing[envVar.Key]; ok {
+					existing[field] = value
+				}
+				envVar.Key = field
+				envVars = append(envVars, envVar)
+				continue
+			}
+			usedBy[envVar.Key] = append(usedBy[envVar.Key], s.Name)
+			i, ok := index[envVar.Key]
+			if !ok {
+				index[envVar.Key] = len(envVars)
+				envVars = append(envVars, envVar)
+				continue
+			}
+			merged := &envVars[i]
+			merged.Required = merged.Required || envVar.Required
+			merged.Secret = merged.Secret || envVar.Secret
+			if merged.Check == "" {
+				merged.Check = envVar.Check
+			}
+			if merged.Type == "" {
+				merged.Type = envVar.Type
+			}
+			if merged.Description == "" {
+				merged.Description = envVar.Description
+			}
+			if merged.ExampleValue == "" {
+				merged.ExampleValue = envVar.ExampleValue
+			}
+		}
+		for key, value := range s.existing {
+			if _, ok := existing[key]; !ok && !split[key] {
+				existing[key] = value
+			}
+		}
+	}
+	return envVars, existing, usedBy
+}
+
+// disagreeingKeys returns the keys that services hold different values or example defaults
+// for. A service without a value or default for a key has no say in it.
+func disagreeingKeys(services []service) map[string]bool {
+	values := make(map[string]string)
+	examples := make(map[string]string)
+	split := make(map[string]bool)
+	differs := func(seen map[string]string, key, value string) bool {
+		if value == "" {
+			return false
+		}
+		if first, ok := seen[key]; ok {
+			return first != value
+		}
+		seen[key] = value
+		return false
+	}
+	for _, s := range services {
+		for _, envVar := range s.envVars {
+			value, ok := s.existing[envVar.Key]
+			if ok && differs(values, envVar.Key, value) || differs(examples, envVar.Key, envVar.ExampleValue) {
+				split[envVar.Key] = true
+			}
+		}
+	}
+	return split
+}
+
+// serviceFieldKey names the field of a key that is asked once per service, such as "web: PORT"
+func serviceFieldKey(serviceName, key string) string {
+	return serviceName + ": " + key
+}
+
+// fieldKey returns the field holding the value of key for a service: its own field when the
+// services disagree on the key, or the shared one
+func (m *model) fieldKey(s service, key string) string {
+	if field := serviceFieldKey(s.Name, key); m.usedBy[field] != nil {
+		return field
+	}
+	return key
+}
+
+// servicesChangedKeys merges the fields of the keys that are new or changed in each service
+// since its stamped run, in the order of the merged fields. Every key of a service without a
+// stamp is new to it. stamped reports whether any service has a stamp to compare against.
+func (m *model) servicesChangedKeys() (keys []string, stamped bool) {
+	changed := make(map[string]bool)
+	for _, s := range m.services {
+		stamped = stamped || s.stamp != nil
+		for _, key := range s.stamp.changedKeys(s.envVars) {
+			changed[m.fieldKey(s, key)] = true
+		}
+	}
+	for _, envVar := range m.envVars {
+		if changed[envVar.Key] {
+			keys = append(keys, envVar.Key)
+		}
+	}
+	return keys, stamped
+}
+
+// prepareServiceForm asks which of the discovered services to set up, all of them by default
+func (m *model) prepareServiceForm() {
+	names := make([]string, len(m.opts.services))
+	for i, s := range m.opts.services {
+		names[i] = s.Name
+	}
+	m.selectedServices = names
+	serviceField := huh.NewMultiSelect[string]().
+		Title(fmt.Sprintf("Found %d templates", len(names))).
+		Description("Choose the services to set up").
+		Value(&m.selectedServices).
+		Options(huh.NewOptions(names...)...).
+		Validate(func(selected []string) error {
+			if len(selected) == 0 {
+				return errors.New("choose at least one service")
+			}
+			return nil
+		})
+
+	m.serviceForm = huh.NewForm(huh.NewGroup(serviceField)).WithTheme(m.theme()).WithKeyMap(m.keys.formKeyMap())
+	m.choosingServices = true
+	m.applyLayout()
+}
+
+// loadServices reads the selected services and merges them into the fields of the form
+func (m *model) loadServices() error {
+	selected := make(map[string]bool, len(m.selectedServices))
+	for _, name := range m.selectedServices {
+		selected[name] = true
+	}
+	m.services = nil
+	for _, s := range m.opts.services {
+		if m.serviceForm == nil || selected[s.Name] {
+			if err := s.load(); err != nil {
+				return err
+			}
+			m.services = append(m.services, s)
+		}
+	}
+	if len(m.services) == 0 {
+		return fmt.Errorf("%w: no services selected", errAborted)
+	}
+	m.envVars, m.existingEnvValues, m.usedBy = mergeServices(m.services)
+	for _, s := range m.services {
+		m.envFileExists = m.envFileExists || s.envFileExists
+	}
+	return nil
+}
+
+// serviceValues returns the values to write to the .env of a service, undoing the changes
+// to that file the user rejected in the review
+func (m *model) serviceValues(s service) map[string]string {
+	values := make(map[string]string, len(s.envVars))
+	for _, envVar := range s.envVars {
+		values[envVar.Key] = m.envValuesToSave[m.fieldKey(s, envVar.Key)]
+	}
+	m.undoRejected(values, s.EnvFile)
+	return values
+}
+
+// serviceAccepted reports whether the review kept at least one change to the .env of a service
+func (m *model) serviceAccepted(s service) bool {
+	for i, c := range m.changes {
+		if c.File == s.EnvFile && m.accepted[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// writeServices writes the .env of every service with accepted changes. A .env tracked by git
+// that would receive secrets stops the whole save before anything is written.
+func (m *model) writeServices() error {
+	for _, s := range m.services {
+		if !m.serviceAccepted(s) {
+			continue
+		}
+		if keys := trackedSecrets(s.git, s.envVars, m.serviceValues(s), m.opts.force); len(keys) > 0 {
+			return fmt.Errorf("%w: %s would contain %s. Untrack it with `git rm --cached %s` or rerun with --force",
+				errTrackedFile, s.git.Path, strings.Join(keys, ", "), s.git.Path)
+		}
+	}
+	for _, s := range m.services {
+		if !m.serviceAccepted(s) {
+			if !m.hasChangesFor(s.EnvFile) {
+				m.writeStampFor(s.EnvFile, s.envVars)
+			}
+			continue
+		}
+		if err := m.writeEnvFileAt(s.EnvFile, s.envVars, m.serviceValues(s)); err != nil {
+			return err
+		}
+	}
+	m.updateGitignore()
+	return nil
+}
+
+// hasChangesFor reports whether the change set touches the given .env file
+func (m *model) hasChangesFor(file string) bool {
+	for _, c := range m.changes {
+		if c.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTemplatesCurrent runs the --quiet-if-current check for every service. A service that
+// could not be checked outweighs one that is out of date.
+func checkTemplatesCurrent(services []service) int {
+	status := exitOK
+	for _, s := range services {
+		if code := checkTemplateCurrent(s.Template, s.EnvFile); code != exitOK && status != exitError {
+			status = code
+		}
+	}
+	return status
+}
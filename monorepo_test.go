@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupMonorepo changes into a repository with an api and a web service sharing DB_HOST
+func setupMonorepo(t *testing.T) {
+	chdirTemp(t, map[string]string{
+		".gitignore":                    "node_modules/\n.env*\n!.env.example\n",
+		"services/api/.env.example":     "DB_HOST=localhost # Database host\nAPI_PORT=8080",
+		"services/api/.env":             "DB_HOST=db.local\nAPI_PORT=8080\n",
+		"services/web/.env.example":     "DB_HOST= # @required\nWEB_PORT=3000",
+		"node_modules/pkg/.env.example": "IGNORED=1",
+	})
+	require.NoError(t, os.Mkdir(".git", 0755))
+}
+
+func TestDiscoverServices(t *testing.T) {
+	setupMonorepo(t)
+
+	services, err := discoverServices(".", ".env.example", ".env")
+	require.NoError(t, err)
+	require.Len(t, services, 2, "templates in ignored directories are skipped")
+	assert.Equal(t, service{Name: "services/api", Template: "services/api/.env.example", EnvFile: "services/api/.env"}, services[0])
+	assert.Equal(t, "services/web", services[1].Name)
+}
+
+func TestMergeServices(t *testing.T) {
+	services := []service{
+		{Name: "api", envVars: []EnvVar{{Key: "DB_HOST", Description: "Database host", ExampleValue: "localhost"}, {Key: "API_PORT"}},
+			existing: map[string]string{"DB_HOST": "db.api"}},
+		{Name: "web", envVars: []EnvVar{{Key: "DB_HOST", Required: true}, {Key: "WEB_PORT", ExampleValue: "3000"}},
+			existing: map[string]string{"DB_HOST": "db.api", "WEB_PORT": "4000"}},
+	}
+	envVars, existing, usedBy := mergeServices(services)
+	assert.Equal(t, []EnvVar{
+		{Key: "DB_HOST", Description: "Database host", ExampleValue: "localhost", Required: true},
+		{Key: "API_PORT"},
+		{Key: "WEB_PORT", ExampleValue: "3000"},
+	}, envVars)
+	assert.Equal(t, map[string]string{"DB_HOST": "db.api", "WEB_PORT": "4000"}, existing)
+	assert.Equal(t, []string{"api", "web"}, usedBy["DB_HOST"])
+	assert.Equal(t, []string{"web"}, usedBy["WEB_PORT"])
+
+	t.Run("differing values get a field per service", func(t *testing.T) {
+		services := []service{
+			{Name: "api", envVars: []EnvVar{{Key: "PORT", ExampleValue: "3000"}, {Key: "LOG_LEVEL", ExampleValue: "info"}},
+				existing: map[string]string{"PORT": "3000"}},
+			{Name: "web", envVars: []EnvVar{{Key: "PORT", ExampleValue: "3000"}, {Key: "LOG_LEVEL", ExampleValue: "debug"}},
+				existing: map[string]string{"PORT": "4000"}},
+		}
+		envVars, existing, usedBy := mergeServices(services)
+		assert.Equal(t, []EnvVar{
+			{Key: "api: PORT", ExampleValue: "3000"},
+			{Key: "api: LOG_LEVEL", ExampleValue: "info"},
+			{Key: "web: PORT", ExampleValue: "3000"},
+			{Key: "web: LOG_LEVEL", ExampleValue: "debug"},
+		}, envVars)
+		assert.Equal(t, map[string]string{"api: PORT": "3000", "web: PORT": "4000"}, existing)
+		assert.Equal(t, []string{"web"}, usedBy["web: PORT"])
+		assert.NotContains(t, usedBy, "PORT")
+	})
+}
+
+func TestModelServices(t *testing.T) {
+	setup := func(t *testing.T) []service {
+		setupMonorepo(t)
+		services, err := discoverServices(".", ".env.example", ".env")
+		require.NoError(t, err)
+		return services
+	}
+
+	t.Run("several services start with the picker", func(t *testing.T) {
+		services := setup(t)
+		m := newModel(options{services: services})
+		m.Init()
+		require.True(t, m.choosingServices)
+		assert.Contains(t, m.View(), "services/api")
+		assert.Equal(t, []string{"services/api", "services/web"}, m.selectedServices, "every service is selected by default")
+	})
+
+	t.Run("shared values are asked once and written to every service", func(t *testing.T) {
+		services := setup(t)
+		m := newModel(options{services: services, answers: map[string]string{"DB_HOST": "db.shared", "API_PORT": "8080", "WEB_PORT": "3000"}})
+		m.load()
+		require.NoError(t, m.err)
+		assert.Len(t, m.fields, 3, "DB_HOST, API_PORT and WEB_PORT")
+
+		require.True(t, m.confirming, "every field was answered")
+		var files []string
+		for _, c := range m.changes {
+			files = append(files, c.File+" "+c.Key)
+		}
+		assert.Equal(t, []string{
+			"services/api/.env DB_HOST",
+			"services/web/.env DB_HOST",
+			"services/web/.env WEB_PORT",
+		}, files)
+		assert.Contains(t, m.diffSummary, `~ Changed: services/api/.env: DB_HOST: "db.local" -> "db.shared"`)
+
+		m.accepted[2] = false // Keep WEB_PORT out of the web .env
+		require.NoError(t, m.save())
+		api, err := readExistingEnvFile("services/api/.env")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "db.shared", "API_PORT": "8080"}, api)
+		web, err := readExistingEnvFile("services/web/.env")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "db.shared"}, web)
+		assert.FileExists(t, "services/api/.env.old")
+		assert.FileExists(t, "services/web/.env.stamp")
+	})
+
+	t.Run("the plain picker sets up the chosen services only", func(t *testing.T) {
+		services := setup(t)
+		var out bytes.Buffer
+		in := strings.NewReader("n\ny\ndb.web\n\ny\n")
+		require.NoError(t, runPlain(newModel(options{services: services}), in, &out, false))
+
+		assert.Contains(t, out.String(), "Set up services/api? [Y/n]: ")
+		assert.Contains(t, out.String(), `+ Added: services/web/.env: DB_HOST="db.web"`)
+		assert.NoFileExists(t, "services/api/.env.old")
+		web, err := readExistingEnvFile("services/web/.env")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "db.web", "WEB_PORT": "3000"}, web)
+	})
+
+	t.Run("only new keys of each stamped service", func(t *testing.T) {
+		services := setup(t)
+		for _, s := range services {
+			envVars, err := readEnvVarsFromFile(s.Template)
+			require.NoError(t, err)
+			require.NoError(t, writeSchemaStamp(stampFilePath(s.EnvFile), newSchemaStamp(envVars)))
+		}
+		require.NoError(t, os.WriteFile("services/web/.env.example", []byte("DB_HOST= # @required\nWEB_PORT=3000\nWEB_URL=http://localhost"), 0644))
+
+		m := newModel(options{services: services, onlyNew: true})
+		m.load()
+		require.NoError(t, m.err)
+		assert.Equal(t, []string{"WEB_URL"}, m.changedKeys)
+		assert.Equal(t, []int{m.envVarIndex("WEB_URL")}, m.visibleFieldIndices())
+
+		m = newModel(options{services: services})
+		m.load()
+		assert.True(t, m.choosingScope, "what's new is offered across the services")
+	})
+
+	t.Run("differing values are kept per service", func(t *testing.T) {
+		chdirTemp(t, map[string]string{
+			"api/.env.example": "PORT=3000\nDB_HOST=localhost",
+			"api/.env":         "PORT=3000\nDB_HOST=db.local\n",
+			"web/.env.example": "PORT=3000\nDB_HOST=localhost",
+			"web/.env":         "PORT=4000\nDB_HOST=db.local\n",
+		})
+		services, err := discoverServices(".", ".env.example", ".env")
+		require.NoError(t, err)
+		m := newModel(options{services: services})
+		m.load()
+		require.NoError(t, m.err)
+		assert.Equal(t, []string{"api: PORT", "DB_HOST", "web: PORT"}, []string{m.envVars[0].Key, m.envVars[1].Key, m.envVars[2].Key})
+		assert.Equal(t, "4000", m.fieldValue(m.envVarIndex("web: PORT")))
+
+		require.NoError(t, m.prepareForConfirmation())
+		assert.Empty(t, m.changes, "nothing changes without edits")
+
+		m.setFieldValue(m.envVarIndex("web: PORT"), "5000")
+		require.NoError(t, m.prepareForConfirmation())
+		require.Len(t, m.changes, 1)
+		assert.Equal(t, `~ Changed: web/.env: PORT: "4000" -> "5000"`, m.changes[0].String())
+		require.NoError(t, m.save())
+		web, err := readExistingEnvFile("web/.env")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"PORT": "5000", "DB_HOST": "db.local"}, web)
+	})
+
+	t.Run("dry run covers every service", func(t *testing.T) {
+		services := setup(t)
+		var out bytes.Buffer
+		err := runDryRun(newModel(options{services: services}), &out, "json")
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), `"services": [`)
+		assert.Contains(t, out.String(), `"file": "services/web/.env"`)
+	})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+var errPathMissing = errors.New("does not exist")
+
+// isPathType reports whether a variable holds a filesystem path
+func isPathType(envVar EnvVar) bool {
+	return envVar.Type == typePath || envVar.Type == typeFile || envVar.Type == typeDir
+}
+
+// expandHome replaces a leading ~ with the home directory. Programs reading .env rarely do it
+// themselves, so path values are saved expanded.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// resolvePath expands ~ and resolves a relative path against dir
+func resolvePath(path, dir string) string {
+	path = expandHome(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// checkPath checks that path exists, can be read and is of the kind valueType asks for.
+// It returns errPathMissing when nothing exists at path.
+func checkPath(valueType, path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errPathMissing
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case valueType == typeFile && info.IsDir():
+		return errors.New("is a directory, expected a file")
+	case valueType == typeDir && !info.IsDir():
+		return errors.New("is not a directory")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.New("is not readable")
+	}
+	defer f.Close()
+	if info.IsDir() {
+		if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+			return errors.New("is not readable")
+		}
+	}
+	return nil
+}
+
+// projectDir returns the directory that relative paths in the value of key are resolved
+// against: the directory of its .env file, or of the first service using it with --recursive
+func (m *model) projectDir(key string) string {
+	for _, s := range m.services {
+		if slices.Contains(m.usedBy[key], s.Name) {
+			return filepath.Dir(s.EnvFile)
+		}
+	}
+	return filepath.Dir(envOutputFilePath)
+}
+
+// validate checks the value of the field at index i. On top of validateValue, a path that
+// exists must be readable and of the right kind. A missing path is only a warning.
+func (m *model) validate(i int, value string) error {
+	envVar := m.envVars[i]
+	if err := validateValue(envVar, value); err != nil {
+		return err
+	}
+	if !isPathType(envVar) || value == "" {
+		return nil
+	}
+	if err := checkPath(envVar.Type, resolvePath(value, m.projectDir(envVar.Key))); err != nil && !errors.Is(err, errPathMissing) {
+		return fmt.Errorf("%s: %s %w", envVar.Key, value, err)
+	}
+	return nil
+}
+
+// validateAll validates the collected value of every field, including those the form never
+// showed: hidden by the filter or --only-new, or set by --answers. It returns the keys that
+// failed and their errors.
+func (m *model) validateAll(values map[string]string) (keys []string, errs []error) {
+	for i, envVar := range m.envVars {
+		if err := m.validate(i, values[envVar.Key]); err != nil {
+			keys = append(keys, envVar.Key)
+			errs = append(errs, err)
+		}
+	}
+	return keys, errs
+}
+
+// pathMissing reports whether the field at index i holds a path that does not exist
+func (m *model) pathMissing(i int) bool {
+	envVar := m.envVars[i]
+	value := m.fieldValue(i)
+	if !isPathType(envVar) || value == "" {
+		return false
+	}
+	return errors.Is(checkPath(envVar.Type, resolvePath(value, m.projectDir(envVar.Key))), errPathMissing)
+}
+
+// missingPaths returns "KEY: path does not exist" for every path value that does not exist,
+// and the directories among them, which can be created on save
+func (m *model) missingPaths() (lines []string, dirs []string) {
+	for i, envVar := range m.envVars {
+		if !m.pathMissing(i) {
+			continue
+		}
+		value := expandHome(m.fieldValue(i))
+		lines = append(lines, fmt.Sprintf("%s: %s %v", envVar.Key, value, errPathMissing))
+		if envVar.Type == typeDir {
+			dirs = append(dirs, value)
+		}
+	}
+	return lines, dirs
+}
+
+// createMissingDirs creates the missing directories of the @type=dir values written to the
+// .env file in envDir
+func createMissingDirs(envDir string, envVars []EnvVar, values map[string]string) {
+	for _, envVar := range envVars {
+		value := values[envVar.Key]
+		if envVar.Type != typeDir || value == "" {
+			continue
+		}
+		dir := resolvePath(value, envDir)
+		if !errors.Is(checkPath(typeDir, dir), errPathMissing) {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Printf("Warning: could not create %s for %s: %v\n", dir, envVar.Key, err)
+			continue
+		}
+		fmt.Printf("Created directory %s for %s.\n", dir, envVar.Key)
+	}
+}
+
+// openPathPicker opens the file picker for the focused path field, starting in the directory
+// of its current value. It reports false for other fields.
+func (m *model) openPathPicker() bool {
+	i := m.focusedFieldIndex()
+	if i == -1 || !isPathType(m.envVars[i]) {
+		return false
+	}
+	envVar := m.envVars[i]
+	start := m.projectDir(envVar.Key)
+	if value := m.fieldValue(i); value != "" {
+		path := resolvePath(value, start)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			start = path
+		} else if info, err := os.Stat(filepath.Dir(path)); err == nil && info.IsDir() {
+			start = filepath.Dir(path)
+		}
+	}
+	m.picked = ""
+	m.pickerField = i
+
+	description := "enter selects, → opens a directory"
+	if envVar.Type == typeFile {
+		description = "enter selects a file or opens a directory"
+	}
+	picker := huh.NewFilePicker().
+		Title("Choose the " + envVar.Type + " for " + envVar.Key).
+		Description(description).
+		CurrentDirectory(start).
+		FileAllowed(envVar.Type != typeDir).
+		DirAllowed(envVar.Type != typeFile).
+		ShowHidden(true).
+		Picking(true).
+		Value(&m.picked)
+	m.pickerForm = huh.NewForm(huh.NewGroup(picker)).WithTheme(m.theme()).WithKeyMap(m.keys.subFormKeyMap())
+	m.applyLayout()
+	return true
+}
+
+// updatePathPicker passes a message to the file picker. A chosen path is written into the
+// field, relative to the project directory when it is inside it.
+func (m *model) updatePathPicker(msg tea.Msg) tea.Cmd {
+	form, cmd := m.pickerForm.Update(msg)
+	if f, ok := form.(*huh.Form); ok {
+		m.pickerForm = f
+	}
+	switch m.pickerForm.State {
+	case huh.StateCompleted:
+		if m.picked != "" {
+			m.setFieldValue(m.pickerField, relativeToProject(m.picked, m.projectDir(m.envVars[m.pickerField].Key)))
+		}
+		m.pickerForm = nil
+	case huh.StateAborted:
+		m.pickerForm = nil
+	}
+	return cmd
+}
+
+// relativeToProject returns path relative to dir when it is inside dir, and absolute otherwise
+func relativeToProject(path, dir string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(absDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
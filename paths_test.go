@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	assert.Equal(t, filepath.Join(home, "certs/tls.pem"), resolvePath("~/certs/tls.pem", "/srv/app"))
+	assert.Equal(t, home, resolvePath("~", "/srv/app"))
+	assert.Equal(t, "/srv/app/certs/tls.pem", resolvePath("certs/tls.pem", "/srv/app"))
+	assert.Equal(t, "/etc/ssl/tls.pem", resolvePath("/etc/ssl/tls.pem", "/srv/app"))
+	assert.Equal(t, "~user/tls.pem", expandHome("~user/tls.pem"), "other users' homes are left alone")
+
+	assert.Equal(t, filepath.Join("certs", "tls.pem"), relativeToProject("/srv/app/certs/tls.pem", "/srv/app"))
+	assert.Equal(t, "/srv/shared/tls.pem", relativeToProject("/srv/shared/tls.pem", "/srv/app"))
+}
+
+func TestCheckPath(t *testing.T) {
+	dir := t.TempDir()
+	file := createTempFileForModel(t, dir, "tls.pem", "cert")
+
+	assert.NoError(t, checkPath(typeFile, file))
+	assert.NoError(t, checkPath(typeDir, dir))
+	assert.NoError(t, checkPath(typePath, file))
+	assert.NoError(t, checkPath(typePath, dir))
+	assert.ErrorIs(t, checkPath(typeFile, filepath.Join(dir, "missing.pem")), errPathMissing)
+	assert.EqualError(t, checkPath(typeFile, dir), "is a directory, expected a file")
+	assert.EqualError(t, checkPath(typeDir, file), "is not a directory")
+
+	if os.Getuid() == 0 {
+		t.Skip("root can read any file")
+	}
+	require.NoError(t, os.Chmod(file, 0))
+	assert.EqualError(t, checkPath(typeFile, file), "is not readable")
+}
+
+func TestPathFields(t *testing.T) {
+	setupDir := func(t *testing.T) string {
+		tmpDir := chdirTemp(t, map[string]string{
+			".env.example":  "TLS_CERT_FILE=certs/tls.pem # Certificate @type=file\nDATA_DIR=var/data # Data directory @type=dir\nCACHE_DIR=~/cache # @type=dir\n",
+			"certs/tls.pem": "cert",
+		})
+		t.Setenv("HOME", filepath.Join(tmpDir, "home"))
+		return tmpDir
+	}
+
+	t.Run("validation and warnings", func(t *testing.T) {
+		tmpDir := setupDir(t)
+		m := initialModel()
+		m.Init()
+
+		assert.ErrorContains(t, m.validate(0, "certs"), "TLS_CERT_FILE: certs is a directory, expected a file")
+		assert.ErrorContains(t, m.validate(1, "certs/tls.pem"), "DATA_DIR: certs/tls.pem is not a directory")
+		assert.NoError(t, m.validate(1, "var/other"), "a missing directory is only a warning")
+		assert.NotContains(t, m.titles[0], "⚠ missing")
+		assert.Contains(t, m.titles[1], "⚠ missing")
+
+		missing, dirs := m.missingPaths()
+		home := filepath.Join(tmpDir, "home")
+		assert.Equal(t, []string{"DATA_DIR: var/data does not exist", "CACHE_DIR: " + filepath.Join(home, "cache") + " does not exist"}, missing)
+		assert.Equal(t, []string{"var/data", filepath.Join(home, "cache")}, dirs)
+
+		values, err := m.collectValues()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(home, "cache"), values["CACHE_DIR"], "~ is expanded in the saved value")
+		assert.Empty(t, m.opts.leaks.check(m.envVars[2], "/tmp/go-build3606245631/b001/cache"), "paths are not random secrets")
+	})
+
+	t.Run("missing directories created on save", func(t *testing.T) {
+		tmpDir := setupDir(t)
+		var out bytes.Buffer
+		require.NoError(t, runPlain(newModel(options{}), strings.NewReader("\n\n\ny\ny\n"), &out, false))
+		assert.Contains(t, out.String(), "Warning: DATA_DIR: var/data does not exist")
+		assert.DirExists(t, filepath.Join(tmpDir, "var/data"))
+		assert.DirExists(t, filepath.Join(tmpDir, "home/cache"))
+	})
+
+	t.Run("missing directories left alone", func(t *testing.T) {
+		tmpDir := setupDir(t)
+		var out bytes.Buffer
+		require.NoError(t, runPlain(newModel(options{}), strings.NewReader("\n\n\nn\ny\n"), &out, false))
+		assert.NoDirExists(t, filepath.Join(tmpDir, "var/data"))
+		assert.FileExists(t, filepath.Join(tmpDir, ".env"))
+	})
+
+	t.Run("file picker", func(t *testing.T) {
+		tmpDir := setupDir(t)
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "keys"), 0755))
+		createTempFileForModel(t, tmpDir, "keys/other.pem", "cert")
+
+		m := initialModel()
+		m.Init()
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlP})
+		require.NotNil(t, m.pickerForm)
+		m.picked = filepath.Join(tmpDir, "keys/other.pem")
+		m.pickerForm.State = huh.StateCompleted
+		m.Update(nil)
+		assert.Nil(t, m.pickerForm)
+		assert.Equal(t, filepath.Join("keys", "other.pem"), m.fieldValue(0), "paths inside the project are kept relative")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlP})
+		require.NotNil(t, m.pickerForm)
+		m.pickerForm.State = huh.StateAborted
+		m.Update(nil)
+		assert.Nil(t, m.pickerForm)
+		assert.Equal(t, filepath.Join("keys", "other.pem"), m.fieldValue(0))
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-isatty"
+)
+
+// isInteractive reports whether both stdin and stdout are terminals
+func isInteractive() bool {
+	isTerminal := func(f *os.File) bool {
+		return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// plainPrompter asks line-based questions, for use without a TTY or with screen readers
+type plainPrompter struct {
+	in         *bufio.Reader
+	out        io.Writer
+	readSecret func() ([]byte, error) // Reads a line without echoing it, set when the input is a terminal
+}
+
+// ask prints a question and returns the answer, or def when the answer is empty.
+// At the end of the input every remaining question takes its default.
+func (p *plainPrompter) ask(question, def string) (string, bool) {
+	fmt.Fprint(p.out, question)
+	line, err := p.in.ReadString('\n')
+	line = strings.TrimRight(line, "\r\n")
+	if err != nil && line == "" {
+		fmt.Fprintln(p.out)
+		return def, false
+	}
+	if line == "" {
+		return def, true
+	}
+	return line, true
+}
+
+// askSecret asks like ask, without echoing the answer when the input is a terminal
+func (p *plainPrompter) askSecret(question, def string) (string, bool) {
+	if p.readSecret == nil {
+		return p.ask(question, def)
+	}
+	fmt.Fprint(p.out, question)
+	answer, err := p.readSecret()
+	fmt.Fprintln(p.out)
+	if err != nil {
+		return def, false
+	}
+	if len(answer) == 0 {
+		return def, true
+	}
+	return string(answer), true
+}
+
+// confirm asks a yes/no question
+func (p *plainPrompter) confirm(question string, def bool) bool {
+	options := "[y/N]"
+	if def {
+		options = "[Y/n]"
+	}
+	for {
+		answer, more := p.ask(fmt.Sprintf("%s %s: ", question, options), "")
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		if !more {
+			return def
+		}
+		fmt.Fprintln(p.out, "Please answer y or n.")
+	}
+}
+
+// runPlain drives the model with line-based prompts instead of the TUI. It uses the same
+// resolution, review and write path. With accessible set, huh's accessible mode asks the questions.
+func runPlain(m *model, in io.Reader, out io.Writer, accessible bool) error {
+	p := &plainPrompter{in: bufio.NewReader(in), out: out}
+	if f, ok := in.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		p.readSecret = func() ([]byte, error) { return term.ReadPassword(f.Fd()) }
+	}
+
+	m.Init()
+	if m.choosingServices {
+		m.selectedServices = nil
+		for _, s := range m.opts.services {
+			if p.confirm(fmt.Sprintf("Set up %s?", s.Name), true) {
+				m.selectedServices = append(m.selectedServices, s.Name)
+			}
+		}
+		m.choosingServices = false
+		m.load()
+	}
+	if m.draftForm != nil {
+		question, details := m.draftQuestion()
+		fmt.Fprintln(out, details)
+		m.resumeDraft = p.confirm(question, true)
+		m.resolveDraft()
+	}
+	if m.err != nil {
+		return m.err
+	}
+	if m.choosingScope {
+		fmt.Fprintf(out, "%d of %d variables are new or changed since your last run: %s\n",
+			len(m.changedKeys), len(m.envVars), strings.Join(m.changedKeys, ", "))
+		m.opts.onlyNew = p.confirm("Only ask for those?", true)
+		m.choosingScope = false
+		m.buildMainForm()
+	}
+
+	if !m.confirming && !m.quitting {
+		if accessible {
+			err := m.form.WithAccessible(true).WithInput(p.in).WithOutput(out).Run()
+			if errors.Is(err, huh.ErrUserAborted) {
+				return errAborted
+			}
+			if err != nil {
+				return err
+			}
+		} else if err := m.askPlain(p); err != nil {
+			return err
+		}
+		if err := m.prepareForConfirmation(); err != nil {
+			return err
+		}
+	}
+	m.runChecks()
+	if checks := m.checkLines(); len(checks) > 0 {
+		fmt.Fprintf(out, "\nChecks:\n  %s\n", strings.Join(checks, "\n  "))
+	}
+	if m.quitting {
+		fmt.Fprintln(out, m.diffSummary)
+		return nil
+	}
+
+	// The output often ends up in CI logs, so secrets are redacted as in --dry-run
+	redacted := make([]change, len(m.changes))
+	for i, c := range m.changes {
+		redacted[i] = c.redacted()
+	}
+	fmt.Fprintf(out, "\nProposed changes:\n%s\n\n", formatChanges(redacted))
+	if warning := m.saveWarning(); warning != "" {
+		fmt.Fprintf(out, "%s\n\n", warning)
+	}
+	missing, dirs := m.missingPaths()
+	for _, line := range missing {
+		fmt.Fprintf(out, "Warning: %s\n", line)
+	}
+	if len(dirs) > 0 {
+		m.createDirs = p.confirm(fmt.Sprintf("Create the missing directories %s?", strings.Join(dirs, " ")), true)
+	}
+	if len(m.ignoreEntries) > 0 {
+		m.addIgnore = p.confirm(fmt.Sprintf("Not ignored by git yet: %s. Add to .gitignore?", strings.Join(m.ignoreEntries, " ")), true)
+	}
+	if !p.confirm(fmt.Sprintf("Save these changes to %s?", envOutputFilePath), false) {
+		fmt.Fprintln(out, "Changes discarded by user.")
+		m.removeDraft()
+		return errAborted
+	}
+	if m.hasLeakWarnings() && !p.confirm("Some values look like leaked or production credentials. Save them anyway?", false) {
+		fmt.Fprintln(out, "Not saved: the credential warnings were not acknowledged.")
+		return errAborted
+	}
+	return m.save()
+}
+
+// askPlain asks one question per visible variable, showing the prefilled value in brackets
+func (m *model) askPlain(p *plainPrompter) error {
+	for _, i := range m.visibleFieldIndices() {
+		envVar := m.envVars[i]
+		question := envVar.Key
+		if envVar.Description != "" {
+			question += " (" + envVar.Description + ")"
+		}
+		def := m.fieldValue(i)
+		switch {
+		case def != "" && isSecret(envVar):
+			question += " [keep current]"
+		case def != "":
+			question += " [" + redactURLPassword(def) + "]"
+		}
+		question += ": "
+
+		ask := p.ask
+		if isSecret(envVar) {
+			ask = p.askSecret
+		}
+		for {
+			value, more := ask(question, def)
+			err := m.validate(i, value)
+			if err == nil {
+				m.setFieldValue(i, value)
+				break
+			}
+			fmt.Fprintf(p.out, "Invalid value: %v\n", err)
+			if !more {
+				return fmt.Errorf("%w: input ended before all values were valid", errValidation)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPlain(t *testing.T) {
+	setupDir := func(t *testing.T, env string) {
+		files := map[string]string{".env.example": "DB_HOST=localhost # Database host\nDB_PASSWORD= # @required\nAPP_NAME=app"}
+		if env != "" {
+			files[".env"] = env
+		}
+		chdirTemp(t, files)
+	}
+
+	t.Run("defaults, validation and save", func(t *testing.T) {
+		setupDir(t, "")
+
+		var out bytes.Buffer
+		in := strings.NewReader("db.local\n\nhunter2\n\ny\n")
+		require.NoError(t, runPlain(newModel(options{}), in, &out, false))
+
+		assert.Contains(t, out.String(), "DB_HOST (Database host) [localhost]: ")
+		assert.Contains(t, out.String(), "Invalid value: DB_PASSWORD is required")
+		assert.Contains(t, out.String(), "Save these changes to .env? [y/N]: ")
+		assert.Contains(t, out.String(), `+ Added: DB_PASSWORD="`+redactedValue+`"`)
+		assert.NotContains(t, out.String(), "hunter2", "secrets are redacted in the proposed changes")
+		written, err := readExistingEnvFile(".env")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"DB_HOST": "db.local", "DB_PASSWORD": "hunter2", "APP_NAME": "app"}, written)
+	})
+
+	t.Run("passwords in URLs are not echoed", func(t *testing.T) {
+		chdirTemp(t, map[string]string{
+			".env.example": "DATABASE_URL=postgres://app@localhost/app # @type=dsn",
+			".env":         "DATABASE_URL=postgres://app:old@db:5432/app\n",
+		})
+
+		var out bytes.Buffer
+		in := strings.NewReader("postgres://app:hunter2@db:5432/app\ny\n")
+		require.NoError(t, runPlain(newModel(options{}), in, &out, false))
+		assert.Contains(t, out.String(), "DATABASE_URL [postgres://app:xxxxx@db:5432/app]: ")
+		assert.Contains(t, out.String(), `~ Changed: DATABASE_URL: "postgres://app:xxxxx@db:5432/app" -> "postgres://app:xxxxx@db:5432/app"`)
+		assert.NotContains(t, out.String(), "hunter2")
+		assert.NotContains(t, out.String(), ":old@")
+	})
+
+	t.Run("secret defaults are not echoed", func(t *testing.T) {
+		setupDir(t, "DB_HOST=localhost\nDB_PASSWORD=s3cret\nAPP_NAME=app\n")
+
+		var out bytes.Buffer
+		require.NoError(t, runPlain(newModel(options{}), strings.NewReader(""), &out, false))
+		assert.Contains(t, out.String(), "DB_PASSWORD [keep current]: ")
+		assert.NotContains(t, out.String(), "s3cret")
+		assert.Contains(t, out.String(), "No changes to apply to .env file.")
+	})
+
+	t.Run("declining the save writes nothing", func(t *testing.T) {
+		setupDir(t, "")
+
+		var out bytes.Buffer
+		err := runPlain(newModel(options{}), strings.NewReader("\npw\n\nn\n"), &out, false)
+		assert.ErrorIs(t, err, errAborted)
+		assert.Contains(t, out.String(), "Changes discarded by user.")
+		_, err = os.Stat(".env")
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("input ending before a required value fails", func(t *testing.T) {
+		setupDir(t, "")
+
+		var out bytes.Buffer
+		err := runPlain(newModel(options{}), strings.NewReader("\n"), &out, false)
+		assert.ErrorIs(t, err, errValidation)
+		assert.ErrorContains(t, err, "input ended")
+	})
+}
+
+func TestPlainPrompterAskSecret(t *testing.T) {
+	t.Run("terminal input is read without echo", func(t *testing.T) {
+		var out bytes.Buffer
+		p := &plainPrompter{in: bufio.NewReader(strings.NewReader("echoed\n")), out: &out}
+		p.readSecret = func() ([]byte, error) { return []byte("hunter2"), nil }
+
+		value, more := p.askSecret("DB_PASSWORD: ", "")
+		assert.True(t, more)
+		assert.Equal(t, "hunter2", value)
+		assert.Equal(t, "DB_PASSWORD: \n", out.String())
+
+		value, _ = p.ask("APP_NAME: ", "")
+		assert.Equal(t, "echoed", value, "other questions still read the buffered input")
+	})
+
+	t.Run("an empty answer keeps the default", func(t *testing.T) {
+		var out bytes.Buffer
+		p := &plainPrompter{in: bufio.NewReader(strings.NewReader("")), out: &out}
+		p.readSecret = func() ([]byte, error) { return nil, nil }
+
+		value, more := p.askSecret("DB_PASSWORD [keep current]: ", "s3cret")
+		assert.True(t, more)
+		assert.Equal(t, "s3cret", value)
+	})
+
+	t.Run("piped input falls back to ask", func(t *testing.T) {
+		var out bytes.Buffer
+		p := &plainPrompter{in: bufio.NewReader(strings.NewReader("hunter2\n")), out: &out}
+
+		value, more := p.askSecret("DB_PASSWORD: ", "")
+		assert.True(t, more)
+		assert.Equal(t, "hunter2", value)
+	})
+}
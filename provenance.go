@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+// valueSource tells where the value shown in a field came from
+type valueSource int
+
+const (
+	sourceNone        valueSource = iota // No value anywhere, the field starts empty
+	sourceDotEnv                         // The existing .env file
+	sourceExample                        // The example default in .env.example
+	sourceEdited                         // Typed by the user in this session
+	sourceEnvironment                    // The environment of the tool's own process
+	sourceAnswers                        // An --answers file
+	sourceEncrypted                      // The decrypted .env.enc file
+)
+
+// defaultPrefillOrder is used unless the user opts into other sources. It leaves out
+// .env.enc, since reading it asks for a passphrase.
+var defaultPrefillOrder = []valueSource{sourceDotEnv, sourceExample}
+
+// withEncrypted ranks .env.enc right after .env, for --key-file without --prefill-order
+func withEncrypted(order []valueSource) []valueSource {
+	if len(order) == 0 {
+		order = defaultPrefillOrder
+	}
+	if containsSource(order, sourceEncrypted) {
+		return order
+	}
+	return slices.Insert(slices.Clone(order), 1, sourceEncrypted)
+}
+
+// prefillSourceNames maps the names accepted by --prefill-order to sources
+var prefillSourceNames = map[string]valueSource{
+	"dotenv":  sourceDotEnv,
+	"enc":     sourceEncrypted,
+	"env":     sourceEnvironment,
+	"example": sourceExample,
+}
+
+// parsePrefillOrder parses a comma separated list such as "env,dotenv,example"
+func parsePrefillOrder(s string) ([]valueSource, error) {
+	var order []valueSource
+	seen := make(map[valueSource]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		source, ok := prefillSourceNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown prefill source %q, expected dotenv, enc, env or example", name)
+		}
+		if seen[source] {
+			return nil, fmt.Errorf("prefill source %q listed twice", name)
+		}
+		seen[source] = true
+		order = append(order, source)
+	}
+	return order, nil
+}
+
+// containsSource reports whether order lists the given source
+func containsSource(order []valueSource, source valueSource) bool {
+	for _, s := range order {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// prefillSources holds the key-value pairs of every source that can prefill a field.
+// Example defaults are not included, they come from the EnvVar itself.
+type prefillSources map[valueSource]map[string]string
+
+// environValues turns os.Environ style entries into a map
+func environValues(environ []string) map[string]string {
+	values := make(map[string]string, len(environ))
+	for _, entry := range environ {
+		if key, value, ok := strings.Cut(entry, "="); ok && key != "" {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func (s valueSource) String() string {
+	switch s {
+	case sourceDotEnv:
+		return "from " + envOutputFilePath
+	case sourceExample:
+		return "example default"
+	case sourceEdited:
+		return "edited"
+	case sourceEnvironment:
+		return "from environment"
+	case sourceAnswers:
+		return "from answers"
+	case sourceEncrypted:
+		return "from .env.enc"
+	default:
+		return "unset"
+	}
+}
+
+var valueSourceNames = map[valueSource]string{
+	sourceNone:        "none",
+	sourceDotEnv:      "dotenv",
+	sourceExample:     "example",
+	sourceEdited:      "edited",
+	sourceEnvironment: "env",
+	sourceAnswers:     "answers",
+	sourceEncrypted:   "enc",
+}
+
+// MarshalText uses the short names of --prefill-order, so tools get stable identifiers
+func (s valueSource) MarshalText() ([]byte, error) {
+	return []byte(valueSourceNames[s]), nil
+}
+
+// resolveInitialValue picks the value a field starts with. The first source in order with a
+// non-empty value wins; failing that, the first source that sets the key at all, even to "".
+func resolveInitialValue(envVar EnvVar, order []valueSource, sources prefillSources) (string, valueSource) {
+	lookup := func(source valueSource) (string, bool) {
+		if source == sourceExample {
+			return envVar.ExampleValue, envVar.ExampleValue != ""
+		}
+		value, ok := sources[source][envVar.Key]
+		return value, ok
+	}
+	for _, source := range order {
+		if value, ok := lookup(source); ok && value != "" {
+			return value, source
+		}
+	}
+	for _, source := range order {
+		if _, ok := lookup(source); ok {
+			return "", source
+		}
+	}
+	return "", sourceNone
+}
+
+// currentSource works out the source of the value a field holds right now
+func (m *model) currentSource(i int) valueSource {
+	value := m.fieldValue(i)
+	key := m.envVars[i].Key
+	original, inDotEnv := m.existingEnvValues[key]
+	environment, inEnvironment := m.processEnv[key]
+	encrypted, inEncrypted := m.opts.encrypted[key]
+	switch {
+	case value == m.initialValues[i]:
+		return m.sources[i]
+	case inDotEnv && value == original:
+		return sourceDotEnv
+	case inEncrypted && value == encrypted:
+		return sourceEncrypted
+	case inEnvironment && value == environment:
+		return sourceEnvironment
+	case value == m.envVars[i].ExampleValue:
+		return sourceExample
+	default:
+		return sourceEdited
+	}
+}
+
+// fieldTitle renders a field title with its source badge and the result of its check
+func (m *model) fieldTitle(i int) string {
+	title := m.envVars[i].Key
+	if source := m.currentSource(i); source != sourceNone {
+		title = fmt.Sprintf("%s  [%s]", title, source)
+	}
+	if result, ok := m.checks[m.envVars[i].Key]; ok {
+		title += "  " + result.badge()
+	}
+	if m.pathMissing(i) {
+		title += "  ⚠ missing"
+	}
+	return title
+}
+
+// refreshBadges updates every field title after values may have changed.
+// The main form caches its rendered content, so it is re-rendered when a title changed.
+func (m *model) refreshBadges() {
+	changed := false
+	for i := range m.fields {
+		input, ok := m.fields[i].(*huh.Input)
+		if !ok {
+			continue
+		}
+		if title := m.fieldTitle(i); title != m.titles[i] {
+			m.titles[i] = title
+			input.Title(title)
+			changed = true
+		}
+	}
+	if changed && m.form != nil {
+		m.form.Update(rerenderMsg{})
+	}
+}
+
+// rerenderMsg makes the main form rebuild its cached view without changing anything
+type rerenderMsg struct{}
+
+// setFieldValue replaces the value of the field at index i, including the text being edited
+func (m *model) setFieldValue(i int, value string) {
+	input, ok := m.fields[i].(*huh.Input)
+	if !ok {
+		return
+	}
+	valuePtr := new(string)
+	*valuePtr = value
+	input.Value(valuePtr)
+	if i == m.focusedFieldIndex() && m.form != nil {
+		m.form.Update(tea.KeyMsg{Type: tea.KeyEnd}) // Put the cursor after the new value
+	}
+	m.typing = -1 // Setting a value is an edit of its own, apart from what was typed before and after
+	m.trackEdits()
+	m.typing = -1
+	m.refreshBadges()
+}
+
+// focusedFieldIndex returns the index in m.fields of the focused main form field, or -1
+func (m *model) focusedFieldIndex() int {
+	if m.form == nil {
+		return -1
+	}
+	focused := m.form.GetFocusedField()
+	if focused == nil {
+		return -1
+	}
+	for i, envVar := range m.envVars {
+		if envVar.Key == focused.GetKey() {
+			return i
+		}
+	}
+	return -1
+}
+
+// resetToDefault sets the focused field back to its example default
+func (m *model) resetToDefault() {
+	if i := m.focusedFieldIndex(); i != -1 {
+		m.setFieldValue(i, m.envVars[i].ExampleValue)
+	}
+}
+
+// restoreOriginal sets the focused field back to the value it has in the existing .env file
+func (m *model) restoreOriginal() {
+	i := m.focusedFieldIndex()
+	if i == -1 {
+		return
+	}
+	if original, ok := m.existingEnvValues[m.envVars[i].Key]; ok {
+		m.setFieldValue(i, original)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInitialValue(t *testing.T) {
+	sources := prefillSources{
+		sourceDotEnv:      {"IN_ENV": "env", "EMPTY_IN_ENV": "", "BOTH": "dotenv"},
+		sourceEnvironment: {"BOTH": "shell", "SHELL_ONLY": "shell"},
+	}
+	withEnv := []valueSource{sourceDotEnv, sourceEnvironment, sourceExample}
+	envFirst := []valueSource{sourceEnvironment, sourceDotEnv, sourceExample}
+	tests := []struct {
+		name           string
+		envVar         EnvVar
+		order          []valueSource
+		expectedValue  string
+		expectedSource valueSource
+	}{
+		{"value from .env wins", EnvVar{Key: "IN_ENV", ExampleValue: "ex"}, defaultPrefillOrder, "env", sourceDotEnv},
+		{"empty .env value falls back to example", EnvVar{Key: "EMPTY_IN_ENV", ExampleValue: "ex"}, defaultPrefillOrder, "ex", sourceExample},
+		{"empty .env value without example", EnvVar{Key: "EMPTY_IN_ENV"}, defaultPrefillOrder, "", sourceDotEnv},
+		{"missing from .env uses example", EnvVar{Key: "OTHER", ExampleValue: "ex"}, defaultPrefillOrder, "ex", sourceExample},
+		{"no value anywhere", EnvVar{Key: "OTHER"}, defaultPrefillOrder, "", sourceNone},
+		{"environment ignored unless listed", EnvVar{Key: "SHELL_ONLY", ExampleValue: "ex"}, defaultPrefillOrder, "ex", sourceExample},
+		{"environment beats example", EnvVar{Key: "SHELL_ONLY", ExampleValue: "ex"}, withEnv, "shell", sourceEnvironment},
+		{".env beats environment by default", EnvVar{Key: "BOTH"}, withEnv, "dotenv", sourceDotEnv},
+		{"environment first when ordered so", EnvVar{Key: "BOTH"}, envFirst, "shell", sourceEnvironment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, source := resolveInitialValue(tt.envVar, tt.order, sources)
+			assert.Equal(t, tt.expectedValue, value)
+			assert.Equal(t, tt.expectedSource, source)
+		})
+	}
+}
+
+func TestParsePrefillOrder(t *testing.T) {
+	order, err := parsePrefillOrder("env, dotenv,example")
+	require.NoError(t, err)
+	assert.Equal(t, []valueSource{sourceEnvironment, sourceDotEnv, sourceExample}, order)
+
+	_, err = parsePrefillOrder("env,vault")
+	assert.ErrorContains(t, err, `unknown prefill source "vault"`)
+
+	_, err = parsePrefillOrder("env,env")
+	assert.ErrorContains(t, err, "listed twice")
+}
+
+func TestEnvironValues(t *testing.T) {
+	values := environValues([]string{"A=1", "B=x=y", "EMPTY=", "=C:", "BROKEN"})
+	assert.Equal(t, map[string]string{"A": "1", "B": "x=y", "EMPTY": ""}, values)
+}
+
+func TestModelProvenance(t *testing.T) {
+	chdirTemp(t, map[string]string{".env.example": "DB_HOST=localhost # Database host\nDB_PORT=5432\nAPI_KEY=", ".env": "DB_HOST=db.internal"})
+
+	m := initialModel()
+	_ = m.Init()
+	require.Nil(t, m.err)
+
+	assert.Equal(t, "DB_HOST  [from .env]", m.fieldTitle(0))
+	assert.Equal(t, "DB_PORT  [example default]", m.fieldTitle(1))
+	assert.Equal(t, "API_KEY", m.fieldTitle(2), "Fields without any value get no badge")
+	assert.Contains(t, m.View(), "[from .env]")
+
+	// The first field is focused, type into it
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	assert.Equal(t, "db.internalx", m.fieldValue(0))
+	assert.Equal(t, sourceEdited, m.currentSource(0))
+	assert.Contains(t, m.View(), "[edited]")
+
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	assert.Equal(t, "localhost", m.fieldValue(0))
+	assert.Equal(t, sourceExample, m.currentSource(0))
+
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+	assert.Equal(t, "db.internal", m.fieldValue(0))
+	assert.Equal(t, sourceDotEnv, m.currentSource(0))
+
+	// Typing after a reset edits the restored value
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	assert.Equal(t, "db.interna", m.fieldValue(0))
+}
+
+func TestModelPrefillFromEnvironment(t *testing.T) {
+	chdirTemp(t, map[string]string{".env.example": "SETUP_ENV_TEST_HOST=localhost\nSETUP_ENV_TEST_PORT=5432", ".env": "SETUP_ENV_TEST_PORT=6543"})
+	t.Setenv("SETUP_ENV_TEST_HOST", "db.from.shell")
+	t.Setenv("SETUP_ENV_TEST_PORT", "7777")
+
+	t.Run("opt-in only", func(t *testing.T) {
+		m := initialModel()
+		_ = m.Init()
+		require.Nil(t, m.err)
+		assert.Equal(t, "localhost", m.fieldValue(0))
+		assert.Nil(t, m.processEnv)
+	})
+
+	t.Run("environment between .env and example", func(t *testing.T) {
+		m := newModel(options{prefillOrder: []valueSource{sourceDotEnv, sourceEnvironment, sourceExample}})
+		_ = m.Init()
+		require.Nil(t, m.err)
+		assert.Equal(t, "db.from.shell", m.fieldValue(0))
+		assert.Equal(t, "SETUP_ENV_TEST_HOST  [from environment]", m.fieldTitle(0))
+		assert.Equal(t, "6543", m.fieldValue(1))
+	})
+
+	t.Run("environment first", func(t *testing.T) {
+		m := newModel(options{prefillOrder: []valueSource{sourceEnvironment, sourceDotEnv, sourceExample}})
+		_ = m.Init()
+		require.Nil(t, m.err)
+		assert.Equal(t, "7777", m.fieldValue(1))
+
+		m.form.NextField()
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+		assert.Equal(t, "6543", m.fieldValue(1), "ctrl+o restores the .env value")
+	})
+}
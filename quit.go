@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// editedCount returns how many fields hold a value other than the one they started with
+func (m *model) editedCount() int {
+	n := 0
+	for i := range m.fields {
+		if m.fieldValue(i) != m.initialValues[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// openQuitForm asks before leaving the main form with unsaved edits. It reports false when
+// there is nothing to lose or the confirmation is turned off, and the form may quit right away.
+func (m *model) openQuitForm() bool {
+	edited := m.editedCount()
+	if !m.opts.confirmQuit || edited == 0 {
+		return false
+	}
+	fields := "field was"
+	if edited > 1 {
+		fields = "fields were"
+	}
+	m.quitConfirmed = false
+	quitField := huh.NewConfirm().
+		Title("Quit without saving?").
+		Description(fmt.Sprintf("%d %s edited. What you entered is kept in %s for the next run.", edited, fields, draftFilePath(envOutputFilePath))).
+		Affirmative("Quit").
+		Negative("Keep editing").
+		Value(&m.quitConfirmed)
+	m.quitForm = huh.NewForm(huh.NewGroup(quitField)).WithTheme(m.theme()).WithKeyMap(m.keys.subFormKeyMap())
+	m.applyLayout()
+	return true
+}
+
+// updateQuitForm passes a message to the quit confirmation. Closing it returns to the form.
+func (m *model) updateQuitForm(msg tea.Msg) tea.Cmd {
+	form, cmd := m.quitForm.Update(msg)
+	if f, ok := form.(*huh.Form); ok {
+		m.quitForm = f
+	}
+	switch m.quitForm.State {
+	case huh.StateCompleted:
+		m.quitForm = nil
+		if m.quitConfirmed {
+			fmt.Println("\nOperation cancelled by user (main form aborted).") // This will print after TUI exits
+			m.saveDraft()
+			m.quitting = true
+			m.aborted = true
+			return tea.Quit
+		}
+		return nil
+	case huh.StateAborted:
+		m.quitForm = nil
+		return nil
+	}
+	return cmd
+}
+
+// quitsNow reports whether a key quits the program right away. The main form and the other
+// full-screen forms handle the quit keys themselves, so the main form can ask first. Keys
+// that also close the open overlay, search bar or sub-form close it instead.
+func (m *model) quitsNow(msg tea.KeyMsg) bool {
+	if !key.Matches(msg, m.keys.Quit) {
+		return false
+	}
+	switch {
+	case m.showingHelp || m.showingChanges || m.quitForm != nil || m.partsForm != nil || m.pickerForm != nil:
+		return !key.Matches(msg, m.keys.Close)
+	case m.filtering:
+		return !key.Matches(msg, m.keys.FilterClear)
+	}
+	return false
+}
+
+// updateHelpOverlay handles a key while the key binding overlay is open
+func (m *model) updateHelpOverlay(msg tea.KeyMsg) {
+	if key.Matches(msg, m.keys.ShowHelp, m.keys.Close) {
+		m.showingHelp = false
+	}
+}
+
+// helpView lists the active key bindings, as configured with key_preset and keys
+func (m *model) helpView() string {
+	theme := m.theme()
+	h := help.New()
+	h.ShowAll = true
+	h.Styles = theme.Help
+	h.FullSeparator = "    "
+
+	body := theme.Focused.Title.Render("Key bindings") + "\n\n" + h.View(m.keys) + "\n\n" +
+		lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("%s close • quitting keeps your answers as a draft", m.keys.ShowHelp.Help().Key))
+
+	style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(m.palette().Border).Padding(0, 1)
+	if m.width > 0 {
+		style = style.Width(m.width - 2)
+	}
+	if m.height > 0 {
+		style = style.MaxHeight(m.height)
+	}
+	return style.Render(body)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuitConfirmation(t *testing.T) {
+	setupDir := func(t *testing.T) *model {
+		chdirTemp(t, map[string]string{".env.example": "APP_NAME=app\nAPP_PORT=8080\n"})
+		m := newModel(options{confirmQuit: true})
+		m.Init()
+		return m
+	}
+
+	t.Run("without edits esc quits", func(t *testing.T) {
+		m := setupDir(t)
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.Nil(t, m.quitForm)
+		assert.Equal(t, huh.StateAborted, m.form.State)
+	})
+
+	t.Run("with edits esc asks first", func(t *testing.T) {
+		m := setupDir(t)
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		require.NotNil(t, m.quitForm)
+		assert.Contains(t, m.View(), "Quit without saving?")
+		assert.Contains(t, m.View(), "1 field was edited")
+		assert.False(t, m.quitConfirmed, "keep editing is the default")
+
+		m.quitForm.State = huh.StateAborted
+		m.Update(nil)
+		assert.Nil(t, m.quitForm)
+		assert.False(t, m.quitting)
+		assert.Equal(t, huh.StateNormal, m.form.State)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		require.NotNil(t, m.quitForm)
+		m.quitConfirmed = true
+		m.quitForm.State = huh.StateCompleted
+		_, cmd := m.Update(nil)
+		require.NotNil(t, cmd)
+		assert.True(t, m.quitting)
+		assert.True(t, m.aborted)
+		assert.FileExists(t, ".env.draft", "the edits are kept")
+	})
+
+	t.Run("turned off", func(t *testing.T) {
+		m := setupDir(t)
+		m.opts.confirmQuit = false
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.Nil(t, m.quitForm)
+		assert.Equal(t, huh.StateAborted, m.form.State)
+	})
+
+	t.Run("vim preset quits every form with its own key", func(t *testing.T) {
+		chdirTemp(t, map[string]string{".env.example": "APP_NAME=app\n"})
+		keys, err := settings{KeyPreset: "vim"}.keyMap()
+		require.NoError(t, err)
+		m := newModel(options{keys: &keys})
+		m.Init()
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.False(t, m.quitting, "esc is not a quit key of the vim preset")
+
+		m.setFieldValue(0, "api")
+		require.NoError(t, m.prepareForConfirmation())
+		m.confirmForm.Init()
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlQ})
+		assert.True(t, m.quitting, "the review quits with the configured key")
+		assert.True(t, m.aborted)
+
+		m = newModel(options{keys: &keys})
+		m.Init()
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+		assert.True(t, m.quitting, "ctrl+c still quits with the vim preset")
+	})
+
+	t.Run("key binding overlay", func(t *testing.T) {
+		m := setupDir(t)
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+		require.True(t, m.showingHelp)
+		view := m.View()
+		assert.Contains(t, view, "Key bindings")
+		assert.Contains(t, view, "ctrl+t")
+		assert.Contains(t, view, "edit parts")
+		assert.Contains(t, view, "esc/ctrl+c")
+
+		m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.False(t, m.showingHelp)
+		assert.Equal(t, huh.StateNormal, m.form.State, "esc closes the overlay, not the form")
+	})
+}
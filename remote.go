@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const maxTemplateSize = 1 << 20 // Larger responses are not a template
+
+var (
+	templateCacheDir = ""                                      // For testability, the user cache directory when empty
+	httpClient       = &http.Client{Timeout: 30 * time.Second} // For testability
+	fetchedTemplates = make(map[string]string)                 // Cached copy of every URL already fetched in this run
+)
+
+var (
+	errChecksumMismatch  = errors.New("checksum does not match the pinned sha256")
+	errTemplateNotCached = errors.New("not downloaded yet")
+)
+
+// isTemplateURL reports whether a template path is an http(s) URL
+func isTemplateURL(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// splitPin separates the optional #sha256=<hex> pin from a template URL
+func splitPin(rawURL string) (string, string, error) {
+	location, fragment, ok := strings.Cut(rawURL, "#")
+	if !ok {
+		return location, "", nil
+	}
+	pin, ok := strings.CutPrefix(fragment, "sha256=")
+	if _, err := hex.DecodeString(pin); !ok || err != nil || len(pin) != sha256.Size*2 {
+		return "", "", fmt.Errorf("invalid pin #%s in %s, expected #sha256=<64 hex digits>", fragment, location)
+	}
+	return location, strings.ToLower(pin), nil
+}
+
+// templateCacheEntry is stored next to a cached template to revalidate it
+type templateCacheEntry struct {
+	URL     string    `json:"url"`
+	ETag    string    `json:"etag,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// fetchTemplate downloads a template URL into the cache and returns the path of the cached copy.
+// A cached copy is revalidated with its ETag and used as is when the server cannot be reached.
+// With a pin, the content must match it, whether downloaded or cached.
+func fetchTemplate(rawURL string) (string, error) {
+	if path, ok := fetchedTemplates[rawURL]; ok {
+		return path, nil
+	}
+	location, pin, err := splitPin(rawURL)
+	if err != nil {
+		return "", err
+	}
+	dir, contentPath, err := templateCachePath(location)
+	if err != nil {
+		return "", err
+	}
+	entryPath := strings.TrimSuffix(contentPath, ".env") + ".json"
+
+	var entry templateCacheEntry
+	_, statErr := os.Stat(contentPath)
+	cached := statErr == nil
+	if cached {
+		if data, err := os.ReadFile(entryPath); err == nil {
+			json.Unmarshal(data, &entry)
+		}
+	}
+
+	content, etag, err := downloadTemplate(location, entry.ETag)
+	switch {
+	case err != nil && cached:
+		fmt.Fprintf(os.Stderr, "Warning: could not fetch %s, using the copy cached %s: %v\n", location, entry.Fetched.Format(time.DateTime), err)
+	case err != nil:
+		return "", fmt.Errorf("error fetching %s: %w", location, err)
+	case content != nil:
+		if err := checkPin(content, pin, location); err != nil {
+			return "", err
+		}
+		if err := writeTemplateCache(dir, contentPath, content); err != nil {
+			return "", err
+		}
+		fallthrough
+	default:
+		// Record the new ETag, or the time of a successful revalidation
+		entry = templateCacheEntry{URL: location, ETag: etag, Fetched: time.Now().UTC()}
+		if data, err := json.MarshalIndent(entry, "", "  "); err == nil {
+			os.WriteFile(entryPath, append(data, '\n'), 0644)
+		}
+	}
+	if content == nil {
+		if content, err = os.ReadFile(contentPath); err != nil {
+			return "", err
+		}
+		if err := checkPin(content, pin, location); err != nil {
+			return "", err
+		}
+	}
+	fetchedTemplates[rawURL] = contentPath
+	return contentPath, nil
+}
+
+// templateCachePath returns the cache directory and the path of the cached copy of a URL
+func templateCachePath(location string) (dir, contentPath string, err error) {
+	dir = templateCacheDir
+	if dir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return "", "", err
+		}
+		dir = filepath.Join(userCache, "setup-env", "templates")
+	}
+	sum := sha256.Sum256([]byte(location))
+	return dir, filepath.Join(dir, hex.EncodeToString(sum[:8])+".env"), nil
+}
+
+// cachedTemplate returns the path of the cached copy of a template URL without going to the
+// network, checked against its pin. It fails with errTemplateNotCached without a copy.
+func cachedTemplate(rawURL string) (string, error) {
+	location, pin, err := splitPin(rawURL)
+	if err != nil {
+		return "", err
+	}
+	_, contentPath, err := templateCachePath(location)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(contentPath)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s: %w", location, errTemplateNotCached)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := checkPin(content, pin, location); err != nil {
+		return "", err
+	}
+	return contentPath, nil
+}
+
+// downloadTemplate fetches a URL. It returns no content when the server confirms that the copy
+// with the given ETag is current.
+func downloadTemplate(location, etag string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusOK:
+		content, err := io.ReadAll(io.LimitReader(resp.Body, maxTemplateSize+1))
+		if err == nil && len(content) > maxTemplateSize {
+			err = fmt.Errorf("template is larger than %d bytes", maxTemplateSize)
+		}
+		return content, resp.Header.Get("ETag"), err
+	default:
+		return nil, "", fmt.Errorf("unexpected response %s", resp.Status)
+	}
+}
+
+// checkPin verifies content against a sha256 pin, if there is one
+func checkPin(content []byte, pin, location string) error {
+	if pin == "" {
+		return nil
+	}
+	sum := sha256.Sum256(content)
+	if got := hex.EncodeToString(sum[:]); got != pin {
+		return fmt.Errorf("%s: %w: got %s", location, errChecksumMismatch, got)
+	}
+	return nil
+}
+
+// writeTemplateCache replaces a cached template so a failed write never leaves half a file
+func writeTemplateCache(dir, contentPath string, content []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), contentPath)
+}
+
+// resolveInclude returns the path of an included template relative to the including one. A
+// remote template may only include other URLs, never local files, and a pinned one only URLs
+// with their own pin, so the pin of the top template vouches for everything it pulls in.
+func resolveInclude(parent, include string) (string, error) {
+	if !isTemplateURL(parent) {
+		if isTemplateURL(include) || filepath.IsAbs(include) {
+			return include, nil
+		}
+		return filepath.Join(filepath.Dir(parent), include), nil
+	}
+	location, parentPin, err := splitPin(parent)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(include) || strings.HasPrefix(include, "/") {
+		return "", fmt.Errorf("remote template %s cannot include the local file %s", location, include)
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(filepath.ToSlash(include))
+	if err != nil {
+		return "", err
+	}
+	resolved := base.ResolveReference(ref).String()
+	if !isTemplateURL(resolved) {
+		return "", fmt.Errorf("remote template %s can only include http(s) URLs, not %s", location, include)
+	}
+	if parentPin != "" {
+		if _, pin, err := splitPin(resolved); err != nil {
+			return "", err
+		} else if pin == "" {
+			return "", fmt.Errorf("pinned template %s cannot include %s without its own #sha256= pin", location, include)
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// templateServer serves templates by path with a content-based ETag and counts the requests
+type templateServer struct {
+	*httptest.Server
+	files       map[string]string
+	requests    int
+	revalidated int // Requests answered with 304 Not Modified
+}
+
+func newTemplateServer(t *testing.T, files map[string]string) *templateServer {
+	s := &templateServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests++
+		content, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		sum := sha256.Sum256([]byte(content))
+		etag := `"` + hex.EncodeToString(sum[:4]) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			s.revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// useTemplateCache points the cache at a temporary directory and forgets earlier fetches
+func useTemplateCache(t *testing.T) {
+	templateCacheDir = t.TempDir()
+	fetchedTemplates = make(map[string]string)
+	t.Cleanup(func() {
+		templateCacheDir = ""
+		fetchedTemplates = make(map[string]string)
+	})
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestTemplateStatusOfURL(t *testing.T) {
+	const template = "LOG_LEVEL=info # Log verbosity\n"
+	useTemplateCache(t)
+	server := newTemplateServer(t, map[string]string{"/env.template": template})
+	url := server.URL + "/env.template#sha256=" + sha256Hex(template)
+	envPath := filepath.Join(t.TempDir(), ".env")
+
+	_, err := templateStatus(url, envPath)
+	assert.ErrorIs(t, err, errTemplateNotCached)
+	assert.Equal(t, exitOutOfDate, checkTemplateCurrent(url, envPath), "a template never fetched is out of date")
+	assert.Zero(t, server.requests, "the check never goes to the network")
+
+	envVars, err := readEnvVarsFromFile(url)
+	require.NoError(t, err)
+	require.NoError(t, writeSchemaStamp(stampFilePath(envPath), newSchemaStamp(envVars)))
+	keys, err := templateStatus(url, envPath)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.Equal(t, 1, server.requests)
+
+	_, err = templateStatus(server.URL+"/env.template#sha256="+sha256Hex("something else"), envPath)
+	assert.ErrorIs(t, err, errChecksumMismatch, "the cached copy is checked against the pin")
+}
+
+func TestFetchTemplate(t *testing.T) {
+	const template = "LOG_LEVEL=info # Log verbosity\n"
+
+	t.Run("cached and revalidated with the etag", func(t *testing.T) {
+		useTemplateCache(t)
+		server := newTemplateServer(t, map[string]string{"/env.template": template})
+		url := server.URL + "/env.template"
+
+		path, err := fetchTemplate(url)
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, template, string(content))
+
+		_, err = fetchTemplate(url)
+		require.NoError(t, err)
+		assert.Equal(t, 1, server.requests, "fetched once per run")
+
+		fetchedTemplates = make(map[string]string) // A later run
+		_, err = fetchTemplate(url)
+		require.NoError(t, err)
+		assert.Equal(t, 1, server.revalidated)
+
+		server.files["/env.template"] = "LOG_LEVEL=debug\n"
+		fetchedTemplates = make(map[string]string)
+		path, err = fetchTemplate(url)
+		require.NoError(t, err)
+		content, err = os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "LOG_LEVEL=debug\n", string(content), "a changed template replaces the cached copy")
+	})
+
+	t.Run("offline use of the cached copy", func(t *testing.T) {
+		useTemplateCache(t)
+		server := newTemplateServer(t, map[string]string{"/env.template": template})
+		url := server.URL + "/env.template"
+		_, err := fetchTemplate(url)
+		require.NoError(t, err)
+
+		server.Close()
+		fetchedTemplates = make(map[string]string)
+		path, err := fetchTemplate(url)
+		require.NoError(t, err)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, template, string(content))
+
+		_, err = fetchTemplate(server.URL + "/never-fetched")
+		assert.ErrorContains(t, err, "error fetching")
+	})
+
+	t.Run("sha256 pin", func(t *testing.T) {
+		useTemplateCache(t)
+		server := newTemplateServer(t, map[string]string{"/env.template": template})
+
+		_, err := fetchTemplate(server.URL + "/env.template#sha256=" + sha256Hex(template))
+		require.NoError(t, err)
+
+		_, err = fetchTemplate(server.URL + "/env.template#sha256=" + sha256Hex("something else"))
+		assert.ErrorIs(t, err, errChecksumMismatch)
+
+		_, err = fetchTemplate(server.URL + "/env.template#sha256=abc")
+		assert.ErrorContains(t, err, "invalid pin")
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		useTemplateCache(t)
+		server := newTemplateServer(t, nil)
+		_, err := fetchTemplate(server.URL + "/env.template")
+		assert.ErrorContains(t, err, "unexpected response 404 Not Found")
+		entries, err := os.ReadDir(templateCacheDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "nothing is cached")
+	})
+}
+
+func TestReadEnvVarsFromURL(t *testing.T) {
+	useTemplateCache(t)
+	server := newTemplateServer(t, map[string]string{
+		"/templates/api.env":           "PORT=8080\n#@include shared/common.env\n",
+		"/templates/shared/common.env": "LOG_LEVEL=info # Log verbosity\n",
+	})
+
+	vars, err := readEnvVarsFromFile(server.URL + "/templates/api.env")
+	require.NoError(t, err)
+	assert.Equal(t, []EnvVar{
+		{Key: "PORT", ExampleValue: "8080"},
+		{Key: "LOG_LEVEL", Description: "Log verbosity", ExampleValue: "info"},
+	}, vars, "relative includes resolve against the URL")
+}
+
+func TestResolveIncludeFromURL(t *testing.T) {
+	const child = "LOG_LEVEL=info\n"
+	pin := "#sha256=" + sha256Hex(child)
+
+	tests := []struct {
+		name, parent, include, want, err string
+	}{
+		{name: "relative", parent: "https://example.com/env/api.env", include: "shared/common.env", want: "https://example.com/env/shared/common.env"},
+		{name: "other URL", parent: "https://example.com/env/api.env", include: "https://cdn.example.com/common.env", want: "https://cdn.example.com/common.env"},
+		{name: "absolute path", parent: "https://example.com/env/api.env", include: "/etc/passwd", err: "cannot include the local file /etc/passwd"},
+		{name: "other scheme", parent: "https://example.com/env/api.env", include: "file:///etc/passwd", err: "can only include http(s) URLs"},
+		{name: "pinned parent, unpinned child", parent: "https://example.com/env/api.env" + pin, include: "shared/common.env", err: "without its own #sha256= pin"},
+		{name: "pinned parent, pinned child", parent: "https://example.com/env/api.env" + pin, include: "shared/common.env" + pin, want: "https://example.com/env/shared/common.env" + pin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveInclude(tt.parent, tt.include)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadEnvVarsFromPinnedURL(t *testing.T) {
+	useTemplateCache(t)
+	const common = "LOG_LEVEL=info\n"
+	files := map[string]string{
+		"/templates/api.env":           "PORT=8080\n#@include shared/common.env\n",
+		"/templates/shared/common.env": common,
+	}
+	server := newTemplateServer(t, files)
+
+	_, err := readEnvVarsFromFile(server.URL + "/templates/api.env#sha256=" + sha256Hex(files["/templates/api.env"]))
+	require.Error(t, err, "a pinned template cannot pull in unpinned content")
+	assert.Contains(t, err.Error(), "without its own #sha256= pin")
+
+	files["/templates/api.env"] = "PORT=8080\n#@include shared/common.env#sha256=" + sha256Hex(common) + "\n"
+	vars, err := readEnvVarsFromFile(server.URL + "/templates/api.env#sha256=" + sha256Hex(files["/templates/api.env"]))
+	require.NoError(t, err)
+	assert.Len(t, vars, 2)
+
+	files["/templates/shared/common.env"] = "LOG_LEVEL=debug\n"
+	useTemplateCache(t)
+	_, err = readEnvVarsFromFile(server.URL + "/templates/api.env#sha256=" + sha256Hex(files["/templates/api.env"]))
+	assert.ErrorIs(t, err, errChecksumMismatch, "the include pin is checked")
+}
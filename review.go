@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// changeKind classifies a single entry of the diff between .env and the form values
+type changeKind int
+
+const (
+	changeAdded changeKind = iota
+	changeChanged
+	changeCleared
+	changeRemoved // In .env but no longer in the template
+)
+
+var changeKindNames = map[changeKind]string{
+	changeAdded:   "added",
+	changeChanged: "changed",
+	changeCleared: "cleared",
+	changeRemoved: "removed",
+}
+
+func (k changeKind) String() string {
+	return changeKindNames[k]
+}
+
+func (k changeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// redactedValue replaces secret values in output meant for other tools
+const redactedValue = "********"
+
+// change is one entry of the diff shown in the review step and printed by --dry-run
+type change struct {
+	Key    string      `json:"key"`
+	Kind   changeKind  `json:"kind"`
+	Old    string      `json:"old"`
+	New    string      `json:"new"`
+	Secret bool        `json:"secret"`
+	Source valueSource `json:"source,omitempty"` // Where the new value came from, unset for removed keys
+	// Warnings from the leaked credential detectors about the new value
+	Warnings []string `json:"warnings,omitempty"`
+	File     string   `json:"file,omitempty"` // The .env file of the change with --recursive
+	Field    string   `json:"-"`              // The form field of the change when it is not Key, see serviceFieldKey
+}
+
+// fieldKey returns the key of the form field the new value of the change comes from
+func (c change) fieldKey() string {
+	if c.Field != "" {
+		return c.Field
+	}
+	return c.Key
+}
+
+// redacted returns the change with the values of a secret masked, and the password of any
+// URL value, such as a database URL, masked as well
+func (c change) redacted() change {
+	if !c.Secret {
+		c.Old, c.New = redactURLPassword(c.Old), redactURLPassword(c.New)
+		return c
+	}
+	if c.Old != "" {
+		c.Old = redactedValue
+	}
+	if c.New != "" {
+		c.New = redactedValue
+	}
+	return c
+}
+
+func (c change) String() string {
+	key := c.Key
+	if c.File != "" {
+		key = c.File + ": " + c.Key
+	}
+	switch c.Kind {
+	case changeChanged:
+		return fmt.Sprintf("~ Changed: %s: \"%s\" -> \"%s\"", key, c.Old, c.New)
+	case changeCleared:
+		return fmt.Sprintf("~ Cleared: %s (was \"%s\")", key, c.Old)
+	case changeRemoved:
+		return fmt.Sprintf("- Removed: %s (was \"%s\")", key, c.Old)
+	default:
+		return fmt.Sprintf("+ Added: %s=\"%s\"", key, c.New)
+	}
+}
+
+// computeChanges diffs the collected form values against the existing .env values.
+// Template keys come first in template order, followed by keys only found in .env.
+func computeChanges(envVars []EnvVar, existing, collected map[string]string, envFileExists bool) []change {
+	var changes []change
+	inTemplate := make(map[string]bool, len(envVars))
+	for _, envVar := range envVars {
+		key := envVar.Key
+		inTemplate[key] = true
+		oldValue, oldExists := existing[key]
+		newValue := collected[key]
+		secret := isSecret(envVar)
+		switch {
+		case !oldExists && envFileExists && newValue == envVar.ExampleValue:
+			// Keeping the template default for a key the existing .env leaves out is not a change
+		case !oldExists:
+			changes = append(changes, change{Key: key, Kind: changeAdded, New: newValue, Secret: secret})
+		case newValue == oldValue:
+		case newValue == "":
+			changes = append(changes, change{Key: key, Kind: changeCleared, Old: oldValue, Secret: secret})
+		default:
+			changes = append(changes, change{Key: key, Kind: changeChanged, Old: oldValue, New: newValue, Secret: secret})
+		}
+	}
+
+	var removed []string
+	for key := range existing {
+		if !inTemplate[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		changes = append(changes, change{Key: key, Kind: changeRemoved, Old: existing[key], Secret: isSecretKey(key)})
+	}
+	return changes
+}
+
+// collectValues returns the value every field holds right now, hidden fields included
+func (m *model) collectValues() (map[string]string, error) {
+	collected := make(map[string]string, len(m.envVars))
+	for i, envVar := range m.envVars {
+		inputField, ok := m.fields[i].(*huh.Input)
+		if !ok {
+			return nil, fmt.Errorf("error: could not cast field for key %s to huh.Input", envVar.Key)
+		}
+		collected[envVar.Key] = inputField.GetValue().(string)
+		if isPathType(envVar) {
+			collected[envVar.Key] = expandHome(collected[envVar.Key])
+		}
+	}
+	return collected, nil
+}
+
+// changeSet diffs the collected values against .env and records where each new value came from.
+// With --recursive every service is diffed against its own .env.
+func (m *model) changeSet(collected map[string]string) []change {
+	changes := computeChanges(m.envVars, m.existingEnvValues, collected, m.envFileExists)
+	if len(m.services) > 0 {
+		changes = nil
+		for _, s := range m.services {
+			values := make(map[string]string, len(s.envVars))
+			for _, envVar := range s.envVars {
+				values[envVar.Key] = collected[m.fieldKey(s, envVar.Key)]
+			}
+			for _, c := range computeChanges(s.envVars, s.existing, values, s.envFileExists) {
+				c.File = s.EnvFile
+				if field := m.fieldKey(s, c.Key); field != c.Key {
+					c.Field = field
+				}
+				changes = append(changes, c)
+			}
+		}
+	}
+	for i := range changes {
+		if index := m.envVarIndex(changes[i].fieldKey()); index != -1 {
+			changes[i].Source = m.currentSource(index)
+			changes[i].Warnings = m.opts.leaks.check(m.envVars[index], changes[i].New)
+		}
+	}
+	return changes
+}
+
+// envVarIndex returns the index of a template key, or -1
+func (m *model) envVarIndex(key string) int {
+	for i, envVar := range m.envVars {
+		if envVar.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatChanges renders a change set as one line per change, followed by its warnings
+func formatChanges(changes []change) string {
+	var lines []string
+	for _, c := range changes {
+		lines = append(lines, c.String())
+		for _, warning := range c.Warnings {
+			lines = append(lines, "    ⚠ "+warning)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// acceptedValues returns the values to write, undoing every change the user rejected in the review
+func (m *model) acceptedValues() map[string]string {
+	values := make(map[string]string, len(m.envValuesToSave))
+	for key, value := range m.envValuesToSave {
+		values[key] = value
+	}
+	m.undoRejected(values, "")
+	return values
+}
+
+// undoRejected reverts the rejected changes to the given .env file in values
+func (m *model) undoRejected(values map[string]string, file string) {
+	for i, c := range m.changes {
+		if m.accepted[i] || c.File != file {
+			continue
+		}
+		switch c.Kind {
+		case changeAdded:
+			delete(values, c.Key)
+		default:
+			values[c.Key] = c.Old
+		}
+	}
+}
+
+// acceptedCount returns how many changes are still selected
+func (m *model) acceptedCount() int {
+	count := 0
+	for _, ok := range m.accepted {
+		if ok {
+			count++
+		}
+	}
+	return count
+}
+
+// updateReview handles the keys that act on the change list. It reports whether the key was used.
+func (m *model) updateReview(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if len(m.changes) == 0 {
+		return false, nil
+	}
+	switch {
+	case key.Matches(msg, m.keys.ReviewUp):
+		m.reviewCursor = max(m.reviewCursor-1, 0)
+	case key.Matches(msg, m.keys.ReviewDown):
+		m.reviewCursor = min(m.reviewCursor+1, len(m.changes)-1)
+	case key.Matches(msg, m.keys.ReviewToggle):
+		m.accepted[m.reviewCursor] = !m.accepted[m.reviewCursor]
+	case key.Matches(msg, m.keys.ReviewEdit):
+		if m.changes[m.reviewCursor].Kind == changeRemoved {
+			return true, nil // Nothing to edit, the key is not in the template
+		}
+		return true, m.editField(m.changes[m.reviewCursor].fieldKey())
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// editField leaves the review and reopens the main form focused on the given key
+func (m *model) editField(editKey string) tea.Cmd {
+	m.confirming = false
+	m.confirmForm = nil
+	m.changes = nil
+	m.accepted = nil
+	m.applyChanges = true
+	m.filterInput.SetValue("")
+	m.filterScope = scopeAll
+	delete(m.opts.answers, editKey) // Answered fields are hidden, editing one brings it back
+
+	target := m.envVarIndex(editKey)
+	visible := m.visibleFieldIndices()
+	position := indexOf(visible, target)
+	if position == -1 {
+		m.opts.onlyNew = false // The field is hidden by the new-and-changed view, show everything
+		position = indexOf(m.visibleFieldIndices(), target)
+	}
+
+	m.buildMainForm()
+	cmd := m.form.Init()
+	for ; position > 0; position-- {
+		m.form.NextField()
+	}
+	return cmd
+}
+
+func indexOf(indices []int, target int) int {
+	for pos, i := range indices {
+		if i == target {
+			return pos
+		}
+	}
+	return -1
+}
+
+// reviewView renders the color-coded change list, scrolled so the cursor stays visible
+func (m *model) reviewView() string {
+	if len(m.changes) == 0 {
+		return m.diffSummary
+	}
+	colors := m.palette()
+	lines := make([]string, len(m.changes))
+	for i, c := range m.changes {
+		cursor := "  "
+		if i == m.reviewCursor {
+			cursor = "› "
+		}
+		check := "[x] "
+		if len(c.Warnings) > 0 {
+			check += "⚠ "
+		}
+		if m.checks[c.fieldKey()].failed() {
+			check += "✗ "
+		}
+		style := colors.Changes[c.Kind]
+		if !m.accepted[i] {
+			check = "[ ] "
+			style = style.Faint(true).Strikethrough(true)
+		}
+		lines[i] = cursor + check + style.Render(c.String())
+		if m.width > 0 {
+			lines[i] = lipgloss.NewStyle().MaxWidth(m.width).Render(lines[i])
+		}
+	}
+
+	vp := viewport.New(m.width, len(lines))
+	if m.width == 0 {
+		vp.Width = lipgloss.Width(strings.Join(lines, "\n"))
+	}
+	if h := m.reviewHeight(); h > 0 && h < len(lines) {
+		vp.Height = h
+	}
+	vp.SetContent(strings.Join(lines, "\n"))
+	if m.reviewCursor >= vp.Height {
+		vp.SetYOffset(m.reviewCursor - vp.Height + 1)
+	}
+
+	help := fmt.Sprintf("%d of %d changes selected • %s • %s • %s",
+		m.acceptedCount(), len(m.changes),
+		helpText(m.keys.ReviewDown), helpText(m.keys.ReviewToggle), helpText(m.keys.ReviewEdit))
+	view := vp.View() + "\n" + lipgloss.NewStyle().Faint(true).Width(m.width).Render(help)
+	if current := m.changes[m.reviewCursor]; len(current.Warnings) > 0 {
+		warning := fmt.Sprintf("⚠ %s %s", current.Key, strings.Join(current.Warnings, ", "))
+		view += "\n" + colors.Error.Width(m.width).Render(warning)
+	}
+	for _, envVar := range m.envVars {
+		result, ok := m.checks[envVar.Key]
+		if !ok {
+			continue
+		}
+		style := lipgloss.NewStyle().Faint(true)
+		if result.failed() {
+			style = colors.Error
+		}
+		view += "\n" + style.Width(m.width).Render(envVar.Key+": "+result.String())
+	}
+	missing, _ := m.missingPaths()
+	for _, line := range missing {
+		view += "\n" + colors.Warning.Width(m.width).Render("⚠ "+line)
+	}
+	return view
+}
+
+// reviewHeight returns how many change lines fit above the confirm form, or 0 when unknown
+func (m *model) reviewHeight() int {
+	if m.height == 0 || m.confirmForm == nil {
+		return 0
+	}
+	missing, _ := m.missingPaths()
+	return max(m.height-lipgloss.Height(m.confirmForm.View())-4-len(m.checks)-len(missing), 3)
+}
+
+func helpText(b key.Binding) string {
+	return b.Help().Key + " " + b.Help().Desc
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeChanges(t *testing.T) {
+	envVars := []EnvVar{
+		{Key: "K1", ExampleValue: "ex1"},
+		{Key: "K2", ExampleValue: "ex2"},
+		{Key: "K3", ExampleValue: "ex3"},
+		{Key: "K4", ExampleValue: "ex4"},
+		{Key: "K5", ExampleValue: "ex5"},
+	}
+	existing := map[string]string{"K1": "old1", "K2": "old2", "K3": "same", "OLD_B": "b", "OLD_A": "a"}
+	collected := map[string]string{"K1": "new1", "K2": "", "K3": "same", "K4": "new4", "K5": "ex5"}
+
+	changes := computeChanges(envVars, existing, collected, true)
+	assert.Equal(t, []change{
+		{Key: "K1", Kind: changeChanged, Old: "old1", New: "new1"},
+		{Key: "K2", Kind: changeCleared, Old: "old2"},
+		{Key: "K4", Kind: changeAdded, New: "new4"},
+		{Key: "OLD_A", Kind: changeRemoved, Old: "a"},
+		{Key: "OLD_B", Kind: changeRemoved, Old: "b"},
+	}, changes)
+
+	t.Run("defaults are added when there is no .env yet", func(t *testing.T) {
+		changes := computeChanges(envVars[4:], map[string]string{}, collected, false)
+		assert.Equal(t, []change{{Key: "K5", Kind: changeAdded, New: "ex5"}}, changes)
+	})
+
+	t.Run("string form matches the diff summary", func(t *testing.T) {
+		assert.Equal(t, `- Removed: OLD_A (was "a")`, changes[3].String())
+	})
+}
+
+func TestModelReview(t *testing.T) {
+	setupReview := func(t *testing.T) *model {
+		chdirTemp(t, map[string]string{".env.example": "K1=ex1\nK2=ex2", ".env": "K1=old1\nK2=old2\nLEGACY=keep"})
+
+		m := initialModel()
+		_ = m.Init()
+		require.Nil(t, m.err)
+		newVal1, newVal2 := "new1", "new2"
+		m.fields[0].(*huh.Input).Value(&newVal1)
+		m.fields[1].(*huh.Input).Value(&newVal2)
+		require.NoError(t, m.prepareForConfirmation())
+		require.True(t, m.confirming)
+		require.Len(t, m.changes, 3)
+		return m
+	}
+
+	t.Run("rejected changes are not written", func(t *testing.T) {
+		m := setupReview(t)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m.Update(tea.KeyMsg{Type: tea.KeySpace})
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m.Update(tea.KeyMsg{Type: tea.KeySpace})
+		assert.Equal(t, []bool{true, false, false}, m.accepted)
+		assert.Contains(t, m.View(), "1 of 3 changes selected")
+		assert.Equal(t, huh.StateNormal, m.confirmForm.State, "Review keys must not reach the confirm form")
+
+		m.confirmForm.State = huh.StateCompleted
+		m.Update(nil)
+		require.Nil(t, m.err)
+
+		values, err := readExistingEnvFile(".env")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"K1": "new1", "K2": "old2", "LEGACY": "keep"}, values)
+	})
+
+	t.Run("nothing selected leaves .env untouched", func(t *testing.T) {
+		m := setupReview(t)
+
+		for i := range m.accepted {
+			m.accepted[i] = false
+		}
+		m.confirmForm.State = huh.StateCompleted
+		m.Update(nil)
+		assert.True(t, m.quitting)
+
+		content, err := os.ReadFile(".env")
+		require.NoError(t, err)
+		assert.Equal(t, "K1=old1\nK2=old2\nLEGACY=keep", string(content))
+	})
+
+	t.Run("edit returns to the form on that field", func(t *testing.T) {
+		m := setupReview(t)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+		assert.False(t, m.confirming)
+		assert.Nil(t, m.confirmForm)
+		require.NotNil(t, m.form)
+		assert.Equal(t, "K2", m.form.GetFocusedField().GetKey())
+		assert.Equal(t, "new2", m.fieldValue(1), "Edits made before the review are kept")
+	})
+}
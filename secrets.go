@@ -0,0 +1,29 @@
+package main
+
+import "strings"
+
+// defaultSecretKeyPatterns are substrings of variable names that are treated as secrets
+// even without an @secret annotation
+var defaultSecretKeyPatterns = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "API_KEY", "APIKEY", "PRIVATE_KEY", "CREDENTIAL"}
+
+// secretKeyPatterns are the patterns in use, set from the secret_patterns setting
+var secretKeyPatterns = defaultSecretKeyPatterns
+
+// isSecret reports whether a variable holds a secret, by annotation or by name
+func isSecret(envVar EnvVar) bool {
+	if envVar.Secret {
+		return true
+	}
+	return isSecretKey(envVar.Key)
+}
+
+// isSecretKey reports whether a variable name matches one of the secret name patterns
+func isSecretKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, pattern := range secretKeyPatterns {
+		if strings.Contains(upper, strings.ToUpper(pattern)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// schemaStamp records the shape of the template that was last applied to a .env file.
+// It is stored in a sidecar file next to .env so later runs can tell which variables
+// were added or changed in .env.example since then.
+type schemaStamp struct {
+	Schema string            `json:"schema"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// stampFilePath returns the sidecar path holding the schema stamp for an .env file
+func stampFilePath(envPath string) string {
+	return envPath + ".stamp"
+}
+
+// hashEnvVar fingerprints a single template entry
+func hashEnvVar(envVar EnvVar) string {
+	sum := sha256.Sum256([]byte(envVar.Key + "\x00" + envVar.Description + "\x00" + envVar.ExampleValue))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// newSchemaStamp computes the stamp for the given template entries
+func newSchemaStamp(envVars []EnvVar) schemaStamp {
+	stamp := schemaStamp{Keys: make(map[string]string, len(envVars))}
+	h := sha256.New()
+	for _, envVar := range envVars {
+		keyHash := hashEnvVar(envVar)
+		stamp.Keys[envVar.Key] = keyHash
+		fmt.Fprintf(h, "%s=%s\n", envVar.Key, keyHash)
+	}
+	stamp.Schema = hex.EncodeToString(h.Sum(nil))
+	return stamp
+}
+
+// changedKeys returns the template keys that are new or changed compared to the stamp
+func (s *schemaStamp) changedKeys(envVars []EnvVar) []string {
+	var keys []string
+	for _, envVar := range envVars {
+		if s == nil || s.Keys[envVar.Key] != hashEnvVar(envVar) {
+			keys = append(keys, envVar.Key)
+		}
+	}
+	return keys
+}
+
+// readSchemaStamp reads a stamp file. A missing file is not an error and yields a nil stamp.
+func readSchemaStamp(filePath string) (*schemaStamp, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading %s: %w", filePath, err)
+	}
+	var stamp schemaStamp
+	if err := json.Unmarshal(data, &stamp); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", filePath, err)
+	}
+	return &stamp, nil
+}
+
+// writeSchemaStamp writes the stamp file with keys in a stable order
+func writeSchemaStamp(filePath string, stamp schemaStamp) error {
+	if stamp.Keys == nil {
+		stamp.Keys = map[string]string{}
+	}
+	data, err := json.MarshalIndent(stamp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(data, '\n'), 0644)
+}
+
+// templateStatus compares a template against the stamp stored next to an .env file.
+// It returns the keys that are new or changed; an empty result means the .env is current.
+// It is meant to be cheap, so a template URL is read from the cache only.
+func templateStatus(templatePath, envPath string) ([]string, error) {
+	envVars, err := readCachedEnvVars(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	stamp, err := readSchemaStamp(stampFilePath(envPath))
+	if err != nil {
+		return nil, err
+	}
+	if stamp != nil && stamp.Schema == newSchemaStamp(envVars).Schema {
+		return nil, nil
+	}
+	keys := stamp.changedKeys(envVars)
+	sort.Strings(keys)
+	return keys, nil
+}
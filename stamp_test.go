@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaStampChangedKeys(t *testing.T) {
+	applied := []EnvVar{
+		{Key: "KEY1", Description: "desc1", ExampleValue: "v1"},
+		{Key: "KEY2", Description: "desc2", ExampleValue: "v2"},
+	}
+	stamp := newSchemaStamp(applied)
+
+	tests := []struct {
+		name         string
+		envVars      []EnvVar
+		expectedKeys []string
+	}{
+		{
+			name:         "unchanged template",
+			envVars:      applied,
+			expectedKeys: nil,
+		},
+		{
+			name: "added key",
+			envVars: append(append([]EnvVar{}, applied...),
+				EnvVar{Key: "KEY3", Description: "desc3"}),
+			expectedKeys: []string{"KEY3"},
+		},
+		{
+			name: "changed default and description",
+			envVars: []EnvVar{
+				{Key: "KEY1", Description: "desc1", ExampleValue: "other"},
+				{Key: "KEY2", Description: "new desc", ExampleValue: "v2"},
+			},
+			expectedKeys: []string{"KEY1", "KEY2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := stamp.changedKeys(tt.envVars)
+			if !reflect.DeepEqual(keys, tt.expectedKeys) {
+				t.Errorf("Expected changed keys %v, got %v", tt.expectedKeys, keys)
+			}
+		})
+	}
+
+	t.Run("nil stamp treats every key as new", func(t *testing.T) {
+		var missing *schemaStamp
+		keys := missing.changedKeys(applied)
+		if !reflect.DeepEqual(keys, []string{"KEY1", "KEY2"}) {
+			t.Errorf("Expected all keys, got %v", keys)
+		}
+	})
+}
+
+func TestSchemaStampRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	stampPath := filepath.Join(tmpDir, ".env.stamp")
+
+	stamp, err := readSchemaStamp(stampPath)
+	if err != nil || stamp != nil {
+		t.Fatalf("Expected nil stamp and no error for missing file, got %v, %v", stamp, err)
+	}
+
+	written := newSchemaStamp([]EnvVar{{Key: "KEY1", ExampleValue: "v1"}})
+	if err := writeSchemaStamp(stampPath, written); err != nil {
+		t.Fatalf("Failed to write stamp: %v", err)
+	}
+	stamp, err = readSchemaStamp(stampPath)
+	if err != nil {
+		t.Fatalf("Failed to read stamp: %v", err)
+	}
+	if !reflect.DeepEqual(*stamp, written) {
+		t.Errorf("Expected stamp %v, got %v", written, *stamp)
+	}
+
+	if err := os.WriteFile(stampPath, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readSchemaStamp(stampPath); err == nil {
+		t.Errorf("Expected an error for a malformed stamp")
+	}
+}
+
+func TestTemplateStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	templatePath := filepath.Join(tmpDir, ".env.example")
+	envPath := filepath.Join(tmpDir, ".env")
+	if err := os.WriteFile(templatePath, []byte("KEY1=v1\nKEY2=v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := templateStatus(templatePath, envPath)
+	if err != nil {
+		t.Fatalf("Did not expect an error, got %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"KEY1", "KEY2"}) {
+		t.Errorf("Expected all keys without a stamp, got %v", keys)
+	}
+
+	envVars, _ := readEnvVarsFromFile(templatePath)
+	if err := writeSchemaStamp(stampFilePath(envPath), newSchemaStamp(envVars)); err != nil {
+		t.Fatal(err)
+	}
+	keys, err = templateStatus(templatePath, envPath)
+	if err != nil || len(keys) != 0 {
+		t.Errorf("Expected a current template, got %v, %v", keys, err)
+	}
+
+	if err := os.WriteFile(templatePath, []byte("KEY1=v1\nKEY2=v2\nKEY0=new # added\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err = templateStatus(templatePath, envPath)
+	if err != nil {
+		t.Fatalf("Did not expect an error, got %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"KEY0"}) {
+		t.Errorf("Expected only the added key, got %v", keys)
+	}
+
+	if code := checkTemplateCurrent(templatePath, envPath); code != exitOutOfDate {
+		t.Errorf("Expected status %d for an out of date .env, got %d", exitOutOfDate, code)
+	}
+	if code := checkTemplateCurrent(filepath.Join(tmpDir, "missing"), envPath); code != exitError {
+		t.Errorf("Expected status %d for a missing template, got %d", exitError, code)
+	}
+}
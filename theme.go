@@ -0,0 +1,166 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// themes maps the names accepted by the theme setting to huh themes
+var themes = map[string]func() *huh.Theme{
+	"base":          huh.ThemeBase,
+	"base16":        huh.ThemeBase16,
+	"catppuccin":    huh.ThemeCatppuccin,
+	"charm":         huh.ThemeCharm,
+	"dracula":       huh.ThemeDracula,
+	"high-contrast": themeHighContrast,
+}
+
+const (
+	defaultThemeName  = "charm"
+	highContrastTheme = "high-contrast"
+)
+
+// themeNames lists the known theme names in order
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// theme returns the huh theme selected for the model. Without colors, the focused button
+// is shown in reverse video, since color is all that sets it apart in most themes.
+func (m *model) theme() *huh.Theme {
+	newTheme, ok := themes[m.opts.theme]
+	if !ok {
+		newTheme = themes[defaultThemeName]
+	}
+	t := newTheme()
+	if m.opts.noColor {
+		t.Focused.FocusedButton = t.Focused.FocusedButton.Reverse(true).Bold(true)
+		t.Focused.Next = t.Focused.FocusedButton
+		t.Focused.BlurredButton = t.Focused.BlurredButton.Reverse(false)
+		t.Blurred.FocusedButton = t.Focused.FocusedButton
+		t.Blurred.BlurredButton = t.Focused.BlurredButton
+	}
+	return t
+}
+
+// themeHighContrast is black or white text with a bright accent and bold titles, for low
+// vision and washed-out displays
+func themeHighContrast() *huh.Theme {
+	t := huh.ThemeBase()
+
+	var (
+		fg     = lipgloss.AdaptiveColor{Light: "#000000", Dark: "#FFFFFF"}
+		bg     = lipgloss.AdaptiveColor{Light: "#FFFFFF", Dark: "#000000"}
+		accent = lipgloss.AdaptiveColor{Light: "#0000AF", Dark: "#FFFF00"}
+		green  = lipgloss.AdaptiveColor{Light: "#005F00", Dark: "#5FFF5F"}
+		red    = lipgloss.AdaptiveColor{Light: "#AF0000", Dark: "#FF5F5F"}
+	)
+
+	t.Focused.Base = t.Focused.Base.BorderForeground(accent)
+	t.Focused.Card = t.Focused.Base
+	t.Focused.Title = t.Focused.Title.Foreground(accent).Bold(true)
+	t.Focused.NoteTitle = t.Focused.NoteTitle.Foreground(accent).Bold(true).MarginBottom(1)
+	t.Focused.Directory = t.Focused.Directory.Foreground(accent).Bold(true)
+	t.Focused.File = t.Focused.File.Foreground(fg)
+	t.Focused.Description = t.Focused.Description.Foreground(fg)
+	t.Focused.ErrorIndicator = t.Focused.ErrorIndicator.Foreground(red).Bold(true)
+	t.Focused.ErrorMessage = t.Focused.ErrorMessage.Foreground(red).Bold(true)
+	t.Focused.SelectSelector = t.Focused.SelectSelector.Foreground(accent).Bold(true)
+	t.Focused.NextIndicator = t.Focused.NextIndicator.Foreground(accent)
+	t.Focused.PrevIndicator = t.Focused.PrevIndicator.Foreground(accent)
+	t.Focused.Option = t.Focused.Option.Foreground(fg)
+	t.Focused.MultiSelectSelector = t.Focused.MultiSelectSelector.Foreground(accent).Bold(true)
+	t.Focused.SelectedOption = t.Focused.SelectedOption.Foreground(green).Bold(true)
+	t.Focused.SelectedPrefix = t.Focused.SelectedPrefix.Foreground(green).Bold(true)
+	t.Focused.UnselectedOption = t.Focused.UnselectedOption.Foreground(fg)
+	t.Focused.UnselectedPrefix = t.Focused.UnselectedPrefix.Foreground(fg)
+	t.Focused.FocusedButton = t.Focused.FocusedButton.Foreground(bg).Background(accent).Bold(true)
+	t.Focused.Next = t.Focused.FocusedButton
+	t.Focused.BlurredButton = t.Focused.BlurredButton.Foreground(fg).Background(bg).Underline(true)
+
+	t.Focused.TextInput.Cursor = t.Focused.TextInput.Cursor.Foreground(accent)
+	t.Focused.TextInput.Placeholder = t.Focused.TextInput.Placeholder.Foreground(fg).Faint(false)
+	t.Focused.TextInput.Prompt = t.Focused.TextInput.Prompt.Foreground(accent).Bold(true)
+	t.Focused.TextInput.Text = t.Focused.TextInput.Text.Foreground(fg)
+
+	t.Blurred = t.Focused
+	t.Blurred.Base = t.Focused.Base.BorderStyle(lipgloss.HiddenBorder())
+	t.Blurred.Card = t.Blurred.Base
+	t.Blurred.Title = t.Blurred.Title.Foreground(fg)
+	t.Blurred.NextIndicator = lipgloss.NewStyle()
+	t.Blurred.PrevIndicator = lipgloss.NewStyle()
+
+	t.Help.ShortKey = t.Help.ShortKey.Foreground(fg).Bold(true)
+	t.Help.ShortDesc = t.Help.ShortDesc.Foreground(fg)
+	t.Help.ShortSeparator = t.Help.ShortSeparator.Foreground(fg)
+	t.Help.FullKey = t.Help.FullKey.Foreground(fg).Bold(true)
+	t.Help.FullDesc = t.Help.FullDesc.Foreground(fg)
+	t.Help.FullSeparator = t.Help.FullSeparator.Foreground(fg)
+
+	t.Group.Title = t.Focused.Title
+	t.Group.Description = t.Focused.Description
+	return t
+}
+
+// palette holds the colors drawn outside the huh forms: the diff, warnings and borders.
+// Each color has a light and a dark variant, picked for the terminal background.
+type palette struct {
+	Changes map[changeKind]lipgloss.Style
+	Warning lipgloss.Style // Things to look at before saving, such as missing paths
+	Error   lipgloss.Style // Failed checks, invalid values and refused saves
+	Border  lipgloss.TerminalColor
+}
+
+// defaultPalette works on light and dark backgrounds alike
+var defaultPalette = palette{
+	Changes: map[changeKind]lipgloss.Style{
+		changeAdded:   lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#1A7F37", Dark: "#3FB950"}),
+		changeChanged: lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#9A6700", Dark: "#D29922"}),
+		changeCleared: lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#8250DF", Dark: "#BC8CFF"}),
+		changeRemoved: lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#CF222E", Dark: "#F85149"}),
+	},
+	Warning: lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#9A6700", Dark: "#D29922"}),
+	Error:   lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#CF222E", Dark: "#F85149"}),
+	Border:  lipgloss.AdaptiveColor{Light: "#8C959F", Dark: "#6E7681"},
+}
+
+// highContrastPalette goes with the high-contrast theme: bold, saturated and near black or
+// near white depending on the background
+var highContrastPalette = palette{
+	Changes: map[changeKind]lipgloss.Style{
+		changeAdded:   lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#005F00", Dark: "#5FFF5F"}),
+		changeChanged: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#5F3F00", Dark: "#FFFF5F"}),
+		changeCleared: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#5F00AF", Dark: "#FF87FF"}),
+		changeRemoved: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#AF0000", Dark: "#FF5F5F"}),
+	},
+	Warning: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#5F3F00", Dark: "#FFFF5F"}),
+	Error:   lipgloss.NewStyle().Bold(true).Foreground(lipgloss.AdaptiveColor{Light: "#AF0000", Dark: "#FF5F5F"}),
+	Border:  lipgloss.AdaptiveColor{Light: "#000000", Dark: "#FFFFFF"},
+}
+
+// palette returns the colors that go with the selected theme
+func (m *model) palette() palette {
+	if m.opts.theme == highContrastTheme {
+		return highContrastPalette
+	}
+	return defaultPalette
+}
+
+// colorDisabled reports whether the environment asks for output without colors: NO_COLOR set
+// to anything, as https://no-color.org asks, or a dumb terminal
+func colorDisabled(getenv func(string) string) bool {
+	return getenv("NO_COLOR") != "" || isDumbTerminal(getenv)
+}
+
+// isDumbTerminal reports whether TERM names a terminal without cursor movement, which cannot
+// show the full-screen form
+func isDumbTerminal(getenv func(string) string) bool {
+	return getenv("TERM") == "dumb"
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThemes(t *testing.T) {
+	for _, name := range themeNames() {
+		m := newModel(options{theme: name})
+		assert.NotNil(t, m.theme(), name)
+	}
+	assert.Contains(t, themeNames(), "high-contrast")
+
+	assert.Equal(t, highContrastPalette.Border, newModel(options{theme: "high-contrast"}).palette().Border)
+	assert.Equal(t, defaultPalette.Border, newModel(options{theme: "dracula"}).palette().Border)
+
+	assert.False(t, initialModel().theme().Focused.FocusedButton.GetReverse())
+	noColor := newModel(options{noColor: true}).theme()
+	assert.True(t, noColor.Focused.FocusedButton.GetReverse(), "the focused button stands out without colors")
+	assert.False(t, noColor.Focused.BlurredButton.GetReverse())
+}
+
+func TestColorDisabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		noColor bool
+		dumb    bool
+	}{
+		{"colors", map[string]string{"TERM": "xterm-256color"}, false, false},
+		{"NO_COLOR", map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"}, true, false},
+		{"empty NO_COLOR", map[string]string{"NO_COLOR": ""}, false, false},
+		{"dumb terminal", map[string]string{"TERM": "dumb"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			assert.Equal(t, tt.noColor, colorDisabled(getenv))
+			assert.Equal(t, tt.dumb, isDumbTerminal(getenv))
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var schemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*$`)
+
+// urlParts is a url or dsn value split into the parts edited by the parts form. Every part is
+// decoded, String encodes them again.
+type urlParts struct {
+	Scheme   string
+	User     string
+	Password string
+	Host     string // Without brackets around IPv6 addresses
+	Port     string
+	Path     string // The database name of a dsn, without the leading slash
+	Query    string // One name=value per line, in their original order
+	fragment string // Kept as is, the form does not edit it
+	dsn      bool
+}
+
+// splitURL splits a url or dsn value into its parts and validates them. Parts that could be
+// parsed are returned with the error of an invalid one.
+func splitURL(value, valueType string) (urlParts, error) {
+	parts := urlParts{dsn: valueType == typeDSN}
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // The url.Error repeats the value, which may hold a password
+		}
+		return parts, fmt.Errorf("not a valid URL: %w", err)
+	}
+	if u.Opaque != "" {
+		return parts, fmt.Errorf("missing // after the scheme %q", u.Scheme)
+	}
+	parts.Scheme = u.Scheme
+	parts.Host = u.Hostname()
+	parts.Port = u.Port()
+	parts.Path = u.Path
+	if parts.dsn {
+		parts.Path = strings.TrimPrefix(u.Path, "/")
+	}
+	parts.fragment = u.Fragment
+	if u.User != nil {
+		parts.User = u.User.Username()
+		parts.Password, _ = u.User.Password()
+	}
+	var lines []string
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, val, hasValue := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return parts, fmt.Errorf("invalid query parameter %q", pair)
+		}
+		if val, err = url.QueryUnescape(val); err != nil {
+			return parts, fmt.Errorf("invalid query parameter %q", pair)
+		}
+		if hasValue {
+			key += "=" + val
+		}
+		lines = append(lines, key)
+	}
+	parts.Query = strings.Join(lines, "\n")
+	return parts, parts.validate()
+}
+
+// validate checks every part with the rules of the parts form
+func (p urlParts) validate() error {
+	if err := validateScheme(p.Scheme); err != nil {
+		return err
+	}
+	if err := validateHost(p.Host, !p.dsn); err != nil {
+		return err
+	}
+	if err := validatePort(p.Port); err != nil {
+		return err
+	}
+	return validateQuery(p.Query)
+}
+
+// String joins the parts into a URL, percent-encoding each part as needed
+func (p urlParts) String() string {
+	u := url.URL{Scheme: p.Scheme, Host: p.Host, Path: p.Path, Fragment: p.fragment}
+	if p.Port != "" || strings.Contains(p.Host, ":") {
+		u.Host = net.JoinHostPort(p.Host, p.Port)
+		u.Host = strings.TrimSuffix(u.Host, ":") // JoinHostPort brackets IPv6 even without a port
+	}
+	if p.User != "" || p.Password != "" {
+		u.User = url.User(p.User)
+		if p.Password != "" {
+			u.User = url.UserPassword(p.User, p.Password)
+		}
+	}
+	if p.dsn && p.Path != "" {
+		u.Path = "/" + p.Path
+	}
+	var query []string
+	for _, line := range strings.Split(p.Query, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			key, val, hasValue := strings.Cut(line, "=")
+			if hasValue {
+				key = url.QueryEscape(key) + "=" + url.QueryEscape(val)
+			} else {
+				key = url.QueryEscape(key)
+			}
+			query = append(query, key)
+		}
+	}
+	u.RawQuery = strings.Join(query, "&")
+	return u.String()
+}
+
+// redacted is String with the password masked, for showing the result on screen
+func (p urlParts) redacted() string {
+	if p.Password != "" {
+		p.Password = "xxxxx"
+	}
+	return p.String()
+}
+
+// redactURLPassword masks the password of a value that is a URL with credentials, as
+// urlParts.redacted does. Any other value is returned as is.
+func redactURLPassword(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+	return u.Redacted()
+}
+
+func validateScheme(scheme string) error {
+	if scheme == "" {
+		return errors.New("the scheme is missing, as in https://")
+	}
+	if !schemePattern.MatchString(scheme) {
+		return fmt.Errorf("invalid scheme %q, use letters, digits, +, - or .", scheme)
+	}
+	return nil
+}
+
+func validateHost(host string, required bool) error {
+	if host == "" {
+		if required {
+			return errors.New("the host is missing")
+		}
+		return nil
+	}
+	if strings.ContainsAny(host, " /?#@[]") {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	if address, _, _ := strings.Cut(host, "%"); strings.Contains(host, ":") && net.ParseIP(address) == nil {
+		return fmt.Errorf("invalid host %q, put the port in its own field", host)
+	}
+	return nil
+}
+
+func validatePort(port string) error {
+	if port == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q, expected a number from 1 to 65535", port)
+	}
+	return nil
+}
+
+func validateQuery(query string) error {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if key, _, _ := strings.Cut(line, "="); line != "" && key == "" {
+			return fmt.Errorf("query parameter %q has no name", line)
+		}
+	}
+	return nil
+}
+
+// hasParts reports whether a variable is edited with the parts form
+func hasParts(envVar EnvVar) bool {
+	return envVar.Type == typeURL || envVar.Type == typeDSN
+}
+
+// openPartsForm opens the parts form for the focused url or dsn field. An empty field starts
+// from its example default. It reports false for other fields.
+func (m *model) openPartsForm() bool {
+	i := m.focusedFieldIndex()
+	if i == -1 || !hasParts(m.envVars[i]) {
+		return false
+	}
+	envVar := m.envVars[i]
+	value, _ := m.fields[i].GetValue().(string)
+	if value == "" {
+		value = envVar.ExampleValue
+	}
+	parts, _ := splitURL(value, envVar.Type)
+	m.parts = &parts
+	m.partsOriginal = parts
+	m.partsField = i
+
+	pathTitle := "Path"
+	if parts.dsn {
+		pathTitle = "Database"
+	}
+	m.partsForm = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().Title("Scheme").Value(&parts.Scheme).Validate(validateScheme),
+			huh.NewInput().Title("User").Value(&parts.User),
+			huh.NewInput().Title("Password").EchoMode(huh.EchoModePassword).Value(&parts.Password),
+			huh.NewInput().Title("Host").Value(&parts.Host).
+				Validate(func(host string) error { return validateHost(host, !parts.dsn) }),
+			huh.NewInput().Title("Port").Value(&parts.Port).Validate(validatePort),
+			huh.NewInput().Title(pathTitle).Value(&parts.Path),
+			huh.NewText().Title("Query parameters").Description("One name=value per line").
+				Lines(3).Value(&parts.Query).Validate(validateQuery),
+		).Title("Edit the parts of " + envVar.Key),
+	).WithTheme(m.theme()).WithKeyMap(m.keys.subFormKeyMap())
+	m.applyLayout()
+	return true
+}
+
+// updatePartsForm passes a message to the parts form. Submitting writes the joined URL into
+// the field, quitting leaves the field as it was.
+func (m *model) updatePartsForm(msg tea.Msg) tea.Cmd {
+	form, cmd := m.partsForm.Update(msg)
+	if f, ok := form.(*huh.Form); ok {
+		m.partsForm = f
+	}
+	switch m.partsForm.State {
+	case huh.StateCompleted:
+		if *m.parts != m.partsOriginal {
+			m.setFieldValue(m.partsField, m.parts.String())
+		}
+		m.partsForm, m.parts = nil, nil
+	case huh.StateAborted:
+		m.partsForm, m.parts = nil, nil
+	}
+	return cmd
+}
+
+// partsView renders the parts form with the URL it currently joins to
+func (m *model) partsView() string {
+	result := "Result: " + m.parts.redacted()
+	if err := m.parts.validate(); err != nil {
+		result = m.palette().Error.Render("Invalid: " + err.Error())
+	}
+	return m.partsForm.View() + "\n" + lipgloss.NewStyle().Width(m.formWidth()).Render(result)
+}
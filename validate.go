@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+// Value types selected with @type=<type>
+const (
+	typeURL = "url" // A URL such as https://api.example.com/v1, edited part by part
+	typeDSN = "dsn" // A database connection URL such as postgres://app@db/app, edited part by part
+
+	typePath = "path" // A file or directory, picked with the file picker
+	typeFile = "file"
+	typeDir  = "dir"
+)
+
+// validTypes lists the values accepted by @type
+var validTypes = map[string]bool{typeURL: true, typeDSN: true, typePath: true, typeFile: true, typeDir: true}
+
+// validateValue checks a value against the rules the template sets for its variable.
+// The form fields and the plain prompts share it.
+func validateValue(envVar EnvVar, value string) error {
+	if envVar.Required && value == "" {
+		return fmt.Errorf("%s is required", envVar.Key)
+	}
+	if value == "" {
+		return nil
+	}
+	switch envVar.Type {
+	case typeURL, typeDSN:
+		if _, err := splitURL(value, envVar.Type); err != nil {
+			return fmt.Errorf("%s: %w", envVar.Key, err)
+		}
+	}
+	return nil
+}